@@ -0,0 +1,345 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2016-09-01/locks"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+	autorestazure "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+)
+
+// Method is a way to authenticate against Azure Resource Manager.
+type Method string
+
+const (
+	ClientCertificate Method = "client certificate"
+	ClientSecret      Method = "client secret"
+	OIDC              Method = "OIDC federated token"
+	ManagedIdentity   Method = "managed identity"
+	AzureCLI          Method = "Azure CLI"
+)
+
+// Config holds the authentication settings, named after the `ARM_*` environment variables of the azurerm provider.
+type Config struct {
+	Environment string
+	TenantID    string
+	ClientID    string
+
+	ClientSecret string
+
+	ClientCertificatePath     string
+	ClientCertificatePassword string
+
+	UseOIDC           bool
+	OIDCToken         string
+	OIDCTokenFilePath string
+	OIDCRequestURL    string
+	OIDCRequestToken  string
+
+	UseMSI      bool
+	MSIEndpoint string
+
+	UseCLI bool
+}
+
+// ConfigFromEnvironment reads the same environment variables as the azurerm provider does.
+func ConfigFromEnvironment() (Config, error) {
+	c := Config{
+		Environment:               getenv("ARM_ENVIRONMENT", "public"),
+		TenantID:                  os.Getenv("ARM_TENANT_ID"),
+		ClientID:                  os.Getenv("ARM_CLIENT_ID"),
+		ClientSecret:              os.Getenv("ARM_CLIENT_SECRET"),
+		ClientCertificatePath:     os.Getenv("ARM_CLIENT_CERTIFICATE_PATH"),
+		ClientCertificatePassword: os.Getenv("ARM_CLIENT_CERTIFICATE_PASSWORD"),
+		OIDCToken:                 os.Getenv("ARM_OIDC_TOKEN"),
+		OIDCTokenFilePath:         os.Getenv("ARM_OIDC_TOKEN_FILE_PATH"),
+		OIDCRequestURL:            getenv("ARM_OIDC_REQUEST_URL", os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")),
+		OIDCRequestToken:          getenv("ARM_OIDC_REQUEST_TOKEN", os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")),
+		MSIEndpoint:               os.Getenv("ARM_MSI_ENDPOINT"),
+	}
+
+	var err error
+	if c.UseOIDC, err = getenvBool("ARM_USE_OIDC", false); err != nil {
+		return c, err
+	}
+	if c.UseMSI, err = getenvBool("ARM_USE_MSI", false); err != nil {
+		return c, err
+	}
+	if c.UseCLI, err = getenvBool("ARM_USE_CLI", true); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// Method selects the authentication method in the same order as the azurerm provider:
+// client certificate, client secret, OIDC, managed identity and finally Azure CLI.
+func (c Config) Method() (Method, error) {
+	switch {
+	case c.ClientCertificatePath != "":
+		return ClientCertificate, c.requireServicePrincipal(ClientCertificate)
+	case c.ClientSecret != "":
+		return ClientSecret, c.requireServicePrincipal(ClientSecret)
+	case c.UseOIDC:
+		if c.OIDCToken == "" && c.OIDCTokenFilePath == "" && (c.OIDCRequestURL == "" || c.OIDCRequestToken == "") {
+			return OIDC, fmt.Errorf("%s authentication requires ARM_OIDC_TOKEN, ARM_OIDC_TOKEN_FILE_PATH or ARM_OIDC_REQUEST_URL with ARM_OIDC_REQUEST_TOKEN", OIDC)
+		}
+		return OIDC, c.requireServicePrincipal(OIDC)
+	case c.UseMSI:
+		return ManagedIdentity, nil
+	case c.UseCLI:
+		return AzureCLI, nil
+	}
+
+	return "", fmt.Errorf("no authentication method is enabled, specify ARM_CLIENT_CERTIFICATE_PATH, ARM_CLIENT_SECRET, ARM_USE_OIDC or ARM_USE_MSI, or enable ARM_USE_CLI")
+}
+
+func (c Config) requireServicePrincipal(m Method) error {
+	if c.ClientID == "" || c.TenantID == "" {
+		return fmt.Errorf("%s authentication requires ARM_CLIENT_ID and ARM_TENANT_ID", m)
+	}
+	return nil
+}
+
+// Credentials authorize the ARM clients used to delete and move resources.
+type Credentials struct {
+	Method      Method
+	Authorizer  autorest.Authorizer
+	Environment autorestazure.Environment
+
+	clients      map[string]resources.Client
+	groupClients map[string]resources.GroupsClient
+	lockClients  map[string]locks.ManagementLocksClient
+	tagClients   map[string]resources.TagsClient
+}
+
+// NewCredentials authenticates with the method selected by the configuration.
+// A token is requested right away, so invalid credentials fail before anything is changed.
+func NewCredentials(c Config) (*Credentials, error) {
+	method, err := c.Method()
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := environment(c.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := &Credentials{Method: method, Environment: env}
+	resource := env.ResourceManagerEndpoint
+
+	if method == AzureCLI {
+		creds.Authorizer, err = auth.NewAuthorizerFromCLIWithResource(resource)
+		if err != nil {
+			return nil, fmt.Errorf("authenticating using %s failed, try `az login`: %v", method, err)
+		}
+		return creds, nil
+	}
+
+	token, err := c.servicePrincipalToken(method, env, resource)
+	if err != nil {
+		return nil, fmt.Errorf("authenticating using %s failed: %v", method, err)
+	}
+	if err := token.EnsureFresh(); err != nil {
+		return nil, fmt.Errorf("authenticating using %s failed: %v", method, err)
+	}
+	creds.Authorizer = autorest.NewBearerAuthorizer(token)
+
+	return creds, nil
+}
+
+func (c Config) servicePrincipalToken(method Method, env autorestazure.Environment, resource string) (*adal.ServicePrincipalToken, error) {
+	if method == ManagedIdentity {
+		if c.MSIEndpoint == "" {
+			return adal.NewServicePrincipalTokenFromManagedIdentity(resource, &adal.ManagedIdentityOptions{ClientID: c.ClientID})
+		}
+		if c.ClientID == "" {
+			return adal.NewServicePrincipalTokenFromMSI(c.MSIEndpoint, resource)
+		}
+		return adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(c.MSIEndpoint, resource, c.ClientID)
+	}
+
+	oauthConfig, err := adal.NewOAuthConfig(env.ActiveDirectoryEndpoint, c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch method {
+	case ClientCertificate:
+		certData, err := os.ReadFile(c.ClientCertificatePath)
+		if err != nil {
+			return nil, fmt.Errorf("reading the client certificate (%s) failed: %v", c.ClientCertificatePath, err)
+		}
+		certificate, privateKey, err := adal.DecodePfxCertificateData(certData, c.ClientCertificatePassword)
+		if err != nil {
+			return nil, fmt.Errorf("decoding the client certificate (%s) failed: %v", c.ClientCertificatePath, err)
+		}
+		return adal.NewServicePrincipalTokenFromCertificate(*oauthConfig, c.ClientID, certificate, privateKey, resource)
+	case ClientSecret:
+		return adal.NewServicePrincipalToken(*oauthConfig, c.ClientID, c.ClientSecret, resource)
+	case OIDC:
+		jwt, err := c.oidcToken()
+		if err != nil {
+			return nil, err
+		}
+		return adal.NewServicePrincipalTokenFromFederatedToken(*oauthConfig, c.ClientID, jwt, resource)
+	}
+
+	return nil, fmt.Errorf("unknown authentication method %q", method)
+}
+
+// oidcToken returns the federated token, either given directly, read from a file or requested from GitHub Actions.
+func (c Config) oidcToken() (string, error) {
+	if c.OIDCToken != "" {
+		return c.OIDCToken, nil
+	}
+
+	if c.OIDCTokenFilePath != "" {
+		data, err := os.ReadFile(c.OIDCTokenFilePath)
+		if err != nil {
+			return "", fmt.Errorf("reading the OIDC token file (%s) failed: %v", c.OIDCTokenFilePath, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	requestURL, err := url.Parse(c.OIDCRequestURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid OIDC request URL: %v", err)
+	}
+	query := requestURL.Query()
+	query.Set("audience", "api://AzureADTokenExchange")
+	requestURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.OIDCRequestToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting the OIDC token failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting the OIDC token failed with status %s", resp.Status)
+	}
+
+	var body struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding the OIDC token response failed: %v", err)
+	}
+	if body.Value == "" {
+		return "", fmt.Errorf("the OIDC token response contains no token")
+	}
+	return body.Value, nil
+}
+
+// ResourcesClient returns an authorized resources client for the subscription, one client per subscription.
+func (creds *Credentials) ResourcesClient(subscriptionID string) resources.Client {
+	if client, ok := creds.clients[subscriptionID]; ok {
+		return client
+	}
+
+	client := resources.NewClientWithBaseURI(creds.Environment.ResourceManagerEndpoint, subscriptionID)
+	client.Authorizer = creds.Authorizer
+	if creds.clients == nil {
+		creds.clients = map[string]resources.Client{}
+	}
+	creds.clients[subscriptionID] = client
+	return client
+}
+
+// LocksClient returns an authorized management locks client for the subscription, one client per subscription.
+func (creds *Credentials) LocksClient(subscriptionID string) locks.ManagementLocksClient {
+	if client, ok := creds.lockClients[subscriptionID]; ok {
+		return client
+	}
+
+	client := locks.NewManagementLocksClientWithBaseURI(creds.Environment.ResourceManagerEndpoint, subscriptionID)
+	client.Authorizer = creds.Authorizer
+	if creds.lockClients == nil {
+		creds.lockClients = map[string]locks.ManagementLocksClient{}
+	}
+	creds.lockClients[subscriptionID] = client
+	return client
+}
+
+// GroupsClient returns an authorized resource groups client for the subscription, one client per subscription.
+func (creds *Credentials) GroupsClient(subscriptionID string) resources.GroupsClient {
+	if client, ok := creds.groupClients[subscriptionID]; ok {
+		return client
+	}
+
+	client := resources.NewGroupsClientWithBaseURI(creds.Environment.ResourceManagerEndpoint, subscriptionID)
+	client.Authorizer = creds.Authorizer
+	if creds.groupClients == nil {
+		creds.groupClients = map[string]resources.GroupsClient{}
+	}
+	creds.groupClients[subscriptionID] = client
+	return client
+}
+
+// TagsClient returns an authorized tags client for the subscription, one client per subscription.
+func (creds *Credentials) TagsClient(subscriptionID string) resources.TagsClient {
+	if client, ok := creds.tagClients[subscriptionID]; ok {
+		return client
+	}
+
+	client := resources.NewTagsClientWithBaseURI(creds.Environment.ResourceManagerEndpoint, subscriptionID)
+	client.Authorizer = creds.Authorizer
+	if creds.tagClients == nil {
+		creds.tagClients = map[string]resources.TagsClient{}
+	}
+	creds.tagClients[subscriptionID] = client
+	return client
+}
+
+// environment translates the provider's ARM_ENVIRONMENT values to the Azure cloud environments.
+func environment(name string) (autorestazure.Environment, error) {
+	switch strings.ToLower(name) {
+	case "", "public":
+		return autorestazure.PublicCloud, nil
+	case "usgovernment":
+		return autorestazure.USGovernmentCloud, nil
+	case "china":
+		return autorestazure.ChinaCloud, nil
+	case "german":
+		return autorestazure.GermanCloud, nil
+	}
+
+	return autorestazure.Environment{}, fmt.Errorf("unknown ARM_ENVIRONMENT %q, use one of: public, usgovernment, china, german", name)
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getenvBool(key string, fallback bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("environment variable %s is not a boolean: %q", key, v)
+	}
+	return b, nil
+}
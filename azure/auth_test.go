@@ -0,0 +1,143 @@
+package azure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConfigMethod(t *testing.T) {
+	servicePrincipal := Config{ClientID: "client", TenantID: "tenant", UseCLI: true}
+
+	t.Run("Client certificate before client secret", func(t *testing.T) {
+		c := servicePrincipal
+		c.ClientCertificatePath = "cert.pfx"
+		c.ClientSecret = "secret"
+		got, _ := c.Method()
+		if got != ClientCertificate {
+			t.Errorf("got %s wanted %s", got, ClientCertificate)
+		}
+	})
+
+	t.Run("Client secret before OIDC", func(t *testing.T) {
+		c := servicePrincipal
+		c.ClientSecret = "secret"
+		c.UseOIDC = true
+		c.OIDCToken = "jwt"
+		got, _ := c.Method()
+		if got != ClientSecret {
+			t.Errorf("got %s wanted %s", got, ClientSecret)
+		}
+	})
+
+	t.Run("OIDC before managed identity", func(t *testing.T) {
+		c := servicePrincipal
+		c.UseOIDC = true
+		c.OIDCToken = "jwt"
+		c.UseMSI = true
+		got, _ := c.Method()
+		if got != OIDC {
+			t.Errorf("got %s wanted %s", got, OIDC)
+		}
+	})
+
+	t.Run("Managed identity before Azure CLI", func(t *testing.T) {
+		c := Config{UseMSI: true, UseCLI: true}
+		got, _ := c.Method()
+		if got != ManagedIdentity {
+			t.Errorf("got %s wanted %s", got, ManagedIdentity)
+		}
+	})
+
+	t.Run("Azure CLI", func(t *testing.T) {
+		got, _ := Config{UseCLI: true}.Method()
+		if got != AzureCLI {
+			t.Errorf("got %s wanted %s", got, AzureCLI)
+		}
+	})
+
+	t.Run("Client secret without tenant", func(t *testing.T) {
+		_, err := Config{ClientID: "client", ClientSecret: "secret"}.Method()
+		if err == nil {
+			t.Errorf("got no error, wanted one")
+		}
+	})
+
+	t.Run("OIDC without token", func(t *testing.T) {
+		c := servicePrincipal
+		c.UseOIDC = true
+		_, err := c.Method()
+		if err == nil {
+			t.Errorf("got no error, wanted one")
+		}
+	})
+
+	t.Run("Nothing enabled", func(t *testing.T) {
+		_, err := Config{}.Method()
+		if err == nil {
+			t.Errorf("got no error, wanted one")
+		}
+	})
+}
+
+func TestConfigFromEnvironment(t *testing.T) {
+	t.Setenv("ARM_CLIENT_ID", "client")
+	t.Setenv("ARM_TENANT_ID", "tenant")
+	t.Setenv("ARM_USE_OIDC", "true")
+	t.Setenv("ARM_USE_CLI", "false")
+	t.Setenv("ARM_OIDC_REQUEST_URL", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "https://token.actions.githubusercontent.com")
+
+	c, err := ConfigFromEnvironment()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !c.UseOIDC || c.UseCLI || c.ClientID != "client" || c.TenantID != "tenant" || c.Environment != "public" {
+		t.Errorf("got %+v", c)
+	}
+	if c.OIDCRequestURL != "https://token.actions.githubusercontent.com" {
+		t.Errorf("got %s wanted the GitHub Actions request URL", c.OIDCRequestURL)
+	}
+
+	t.Run("Invalid boolean", func(t *testing.T) {
+		t.Setenv("ARM_USE_MSI", "maybe")
+		_, err := ConfigFromEnvironment()
+		if err == nil {
+			t.Errorf("got no error, wanted one")
+		}
+	})
+}
+
+func TestOIDCTokenRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != "api://AzureADTokenExchange" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"value": "jwt"}`))
+	}))
+	defer server.Close()
+
+	c := Config{OIDCRequestURL: server.URL + "?api-version=2.0", OIDCRequestToken: "request-token"}
+	got, err := c.oidcToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "jwt" {
+		t.Errorf("got %s wanted %s", got, "jwt")
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	env, err := environment("china")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env.Name != "AzureChinaCloud" {
+		t.Errorf("got %s wanted %s", env.Name, "AzureChinaCloud")
+	}
+
+	if _, err := environment("moon"); err == nil {
+		t.Errorf("got no error, wanted one")
+	}
+}
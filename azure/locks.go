@@ -0,0 +1,129 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2016-09-01/locks"
+	"github.com/aristosvo/aztfmove/azureid"
+)
+
+// Lock is a management lock, with everything needed to create it again.
+type Lock struct {
+	ID     string   `json:"id"`
+	Level  string   `json:"level"`
+	Notes  string   `json:"notes,omitempty"`
+	Owners []string `json:"owners,omitempty"`
+}
+
+// Scope returns the ID of the subscription, resource group or resource the lock is applied to.
+func (l Lock) Scope() string {
+	scope, _, _ := azureid.LockScope(l.ID)
+	return scope
+}
+
+func (l Lock) String() string {
+	return fmt.Sprintf("%s (%s)", l.ID, l.Level)
+}
+
+// ListLocks returns the locks on the resource group, the resources in it and the subscription it's in.
+func ListLocks(ctx context.Context, client locks.ManagementLocksClient, resourceGroup string) ([]Lock, error) {
+	iterator, err := client.ListAtResourceGroupLevelComplete(ctx, resourceGroup, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Lock
+	for iterator.NotDone() {
+		lock := iterator.Value()
+		if lock.ID != nil && lock.ManagementLockProperties != nil {
+			l := Lock{ID: *lock.ID, Level: string(lock.Level)}
+			if lock.Notes != nil {
+				l.Notes = *lock.Notes
+			}
+			if lock.Owners != nil {
+				for _, owner := range *lock.Owners {
+					if owner.ApplicationID != nil {
+						l.Owners = append(l.Owners, *owner.ApplicationID)
+					}
+				}
+			}
+			found = append(found, l)
+		}
+		if err := iterator.NextWithContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// DeleteLock removes a lock, a lock which doesn't exist anymore is not an error.
+func DeleteLock(ctx context.Context, client locks.ManagementLocksClient, lock Lock) error {
+	scope, name, err := lockScope(lock)
+	if err != nil {
+		return err
+	}
+
+	if scope.Type() == "" {
+		_, err = client.DeleteAtResourceGroupLevel(ctx, scope.ResourceGroup, name)
+	} else {
+		parent, resourceType := parentPath(scope)
+		_, err = client.DeleteAtResourceLevel(ctx, scope.ResourceGroup, scope.Namespace, parent, resourceType, scope.Name(), name)
+	}
+	if NotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// CreateLock creates the lock, or updates it when it exists already.
+func CreateLock(ctx context.Context, client locks.ManagementLocksClient, lock Lock) error {
+	scope, name, err := lockScope(lock)
+	if err != nil {
+		return err
+	}
+
+	properties := &locks.ManagementLockProperties{Level: locks.LockLevel(lock.Level)}
+	if lock.Notes != "" {
+		properties.Notes = &lock.Notes
+	}
+	if len(lock.Owners) > 0 {
+		var owners []locks.ManagementLockOwner
+		for i := range lock.Owners {
+			owners = append(owners, locks.ManagementLockOwner{ApplicationID: &lock.Owners[i]})
+		}
+		properties.Owners = &owners
+	}
+	parameters := locks.ManagementLockObject{ManagementLockProperties: properties}
+
+	if scope.Type() == "" {
+		_, err = client.CreateOrUpdateAtResourceGroupLevel(ctx, scope.ResourceGroup, name, parameters)
+		return err
+	}
+	parent, resourceType := parentPath(scope)
+	_, err = client.CreateOrUpdateAtResourceLevel(ctx, scope.ResourceGroup, scope.Namespace, parent, resourceType, scope.Name(), name, parameters)
+	return err
+}
+
+// lockScope parses the scope of a lock, only locks on resource groups and resources in them are supported.
+func lockScope(lock Lock) (azureid.ID, string, error) {
+	s, name, ok := azureid.LockScope(lock.ID)
+	if !ok {
+		return azureid.ID{}, "", fmt.Errorf("%s is not a management lock", lock.ID)
+	}
+	scope, err := azureid.Parse(s)
+	if err != nil || scope.ResourceGroup == "" || scope.Scope != nil {
+		return azureid.ID{}, "", fmt.Errorf("lock %s is not on a resource group or a resource in it", lock.ID)
+	}
+	return scope, name, nil
+}
+
+// parentPath returns the parent resource path and the type of a (child) resource, as the locks API expects these.
+func parentPath(id azureid.ID) (string, string) {
+	var parent []string
+	for i := 0; i < len(id.Types)-1; i++ {
+		parent = append(parent, id.Types[i], id.Names[i])
+	}
+	return strings.Join(parent, "/"), id.Types[len(id.Types)-1]
+}
@@ -0,0 +1,57 @@
+package azure
+
+import (
+	"testing"
+)
+
+func TestLockScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		parent     string
+		typ        string
+		lockName   string
+		shouldFail bool
+	}{
+		{
+			name:     "Resource group",
+			id:       "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Authorization/locks/group",
+			lockName: "group",
+		},
+		{
+			name:     "Child resource",
+			id:       "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Network/virtualNetworks/vnet/subnets/apps/providers/Microsoft.Authorization/locks/subnet",
+			parent:   "virtualNetworks/vnet",
+			typ:      "subnets",
+			lockName: "subnet",
+		},
+		{
+			name:       "Subscription",
+			id:         "/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Authorization/locks/subscription",
+			shouldFail: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, name, err := lockScope(Lock{ID: tt.id})
+			if (err != nil) != tt.shouldFail {
+				t.Fatalf("got error %v wanted failure %t", err, tt.shouldFail)
+			}
+			if tt.shouldFail {
+				return
+			}
+			if name != tt.lockName {
+				t.Errorf("got %v wanted %v", name, tt.lockName)
+			}
+			if tt.typ == "" {
+				if scope.Type() != "" {
+					t.Errorf("got %v wanted a resource group", scope.Type())
+				}
+				return
+			}
+			if parent, typ := parentPath(scope); parent != tt.parent || typ != tt.typ {
+				t.Errorf("got %v %v wanted %v %v", parent, typ, tt.parent, tt.typ)
+			}
+		})
+	}
+}
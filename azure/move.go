@@ -0,0 +1,135 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+	autorestazure "github.com/Azure/go-autorest/autorest/azure"
+)
+
+// MoveError is a failed (validation of a) move, with the errors Azure reports for the individual resources.
+type MoveError struct {
+	Code    string
+	Message string
+	Details []MoveErrorDetail
+}
+
+type MoveErrorDetail struct {
+	Code    string
+	Target  string
+	Message string
+}
+
+func (e *MoveError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// ValidateMove asks Azure whether the resources can be moved, without moving them.
+// It waits for the long-running validation and returns a *MoveError when the move would fail.
+func ValidateMove(ctx context.Context, client resources.Client, sourceResourceGroup string, azureIDs []string, targetResourceGroupID string) error {
+	moveInfo := resources.MoveInfo{
+		ResourcesProperty:   &azureIDs,
+		TargetResourceGroup: &targetResourceGroupID,
+	}
+
+	future, err := client.ValidateMoveResources(ctx, sourceResourceGroup, moveInfo)
+	if err != nil {
+		return moveError(err)
+	}
+	if err := future.WaitForCompletionRef(ctx, client.Client); err != nil {
+		return moveError(err)
+	}
+
+	return nil
+}
+
+// Move moves the resources to the target resource group and waits until Azure is done.
+func Move(ctx context.Context, client resources.Client, sourceResourceGroup string, azureIDs []string, targetResourceGroupID string) error {
+	moveInfo := resources.MoveInfo{
+		ResourcesProperty:   &azureIDs,
+		TargetResourceGroup: &targetResourceGroupID,
+	}
+
+	future, err := client.MoveResources(ctx, sourceResourceGroup, moveInfo)
+	if err != nil {
+		return moveError(err)
+	}
+	if err := future.WaitForCompletionRef(ctx, client.Client); err != nil {
+		return moveError(err)
+	}
+
+	return nil
+}
+
+// moveError extracts the Azure service error, including the nested per-resource details, from an SDK error.
+func moveError(err error) error {
+	var serviceError *autorestazure.ServiceError
+	var requestError *autorestazure.RequestError
+	if errors.As(err, &requestError) && requestError.ServiceError != nil {
+		serviceError = requestError.ServiceError
+	} else if !errors.As(err, &serviceError) {
+		return err
+	}
+
+	moveErr := &MoveError{Code: serviceError.Code, Message: serviceError.Message}
+	for _, detail := range serviceError.Details {
+		moveErr.Details = append(moveErr.Details, flattenDetail(detail, "")...)
+	}
+	return moveErr
+}
+
+// flattenDetail returns the innermost details of an error detail, as Azure nests the per-resource errors.
+// Nested details without a target inherit the target of their parent.
+func flattenDetail(detail map[string]interface{}, target string) []MoveErrorDetail {
+	str := func(key string) string {
+		s, _ := detail[key].(string)
+		return strings.TrimSpace(s)
+	}
+	if t := str("target"); t != "" {
+		target = t
+	}
+
+	if nested, ok := detail["details"].([]interface{}); ok && len(nested) > 0 {
+		var details []MoveErrorDetail
+		for _, n := range nested {
+			if m, ok := n.(map[string]interface{}); ok {
+				details = append(details, flattenDetail(m, target)...)
+			}
+		}
+		return details
+	}
+
+	return []MoveErrorDetail{{Code: str("code"), Target: target, Message: str("message")}}
+}
+
+// Delete deletes a resource by its ID and waits until Azure is done.
+func Delete(ctx context.Context, client resources.Client, azureID, apiVersion string) error {
+	future, err := client.DeleteByID(ctx, azureID, apiVersion)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, client.Client)
+}
+
+// ListResourceGroup returns the IDs of all resources in the resource group.
+func ListResourceGroup(ctx context.Context, client resources.Client, resourceGroup string) ([]string, error) {
+	iterator, err := client.ListByResourceGroupComplete(ctx, resourceGroup, "", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for iterator.NotDone() {
+		if resource := iterator.Value(); resource.ID != nil {
+			ids = append(ids, *resource.ID)
+		}
+		if err := iterator.NextWithContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
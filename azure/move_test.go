@@ -0,0 +1,72 @@
+package azure
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	autorestazure "github.com/Azure/go-autorest/autorest/azure"
+)
+
+func TestMoveError(t *testing.T) {
+	serviceError := &autorestazure.ServiceError{
+		Code:    "ResourceMoveProviderValidationFailed",
+		Message: "Resource move validation failed.",
+		Details: []map[string]interface{}{
+			{
+				"code":    "ResourceMoveNotSupported",
+				"target":  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app",
+				"message": "Cannot move resources because some site(s) have VNet integration enabled.",
+			},
+			{
+				"target": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/sa",
+				"details": []interface{}{
+					map[string]interface{}{"code": "ScopeLocked", "message": "The scope is locked. "},
+				},
+			},
+		},
+	}
+
+	t.Run("Polling error", func(t *testing.T) {
+		err := moveError(autorest.NewErrorWithError(serviceError, "resources.ValidateMoveResourcesFuture", "Result", nil, "Polling failure"))
+		got, ok := err.(*MoveError)
+		if !ok {
+			t.Fatalf("got %T wanted *MoveError", err)
+		}
+
+		wanted := &MoveError{
+			Code:    "ResourceMoveProviderValidationFailed",
+			Message: "Resource move validation failed.",
+			Details: []MoveErrorDetail{
+				{
+					Code:    "ResourceMoveNotSupported",
+					Target:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app",
+					Message: "Cannot move resources because some site(s) have VNet integration enabled.",
+				},
+				{
+					Code:    "ScopeLocked",
+					Target:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/sa",
+					Message: "The scope is locked.",
+				},
+			},
+		}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+
+	t.Run("Request error", func(t *testing.T) {
+		requestError := &autorestazure.RequestError{ServiceError: serviceError}
+		err := moveError(autorest.NewErrorWithError(requestError, "resources.Client", "ValidateMoveResources", nil, "Failure sending request"))
+		if _, ok := err.(*MoveError); !ok {
+			t.Errorf("got %T wanted *MoveError", err)
+		}
+	})
+
+	t.Run("Other error", func(t *testing.T) {
+		err := moveError(autorest.NewError("resources.Client", "ValidateMoveResources", "no connection"))
+		if _, ok := err.(*MoveError); ok {
+			t.Errorf("got *MoveError wanted the original error")
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+	"github.com/Azure/go-autorest/autorest"
+	autorestazure "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/aristosvo/aztfmove/azureid"
+)
+
+// Get returns the properties of a resource as needed to create it again with Recreate.
+func Get(ctx context.Context, client resources.Client, azureID, apiVersion string) (json.RawMessage, error) {
+	resource, err := client.GetByID(ctx, azureID, apiVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(resource)
+}
+
+// NotFound reports whether a request failed because the resource doesn't exist (anymore).
+func NotFound(err error) bool {
+	var requestErr *autorestazure.RequestError
+	if errors.As(err, &requestErr) && requestErr.StatusCode == http.StatusNotFound {
+		return true
+	}
+	var detailed autorest.DetailedError
+	return errors.As(err, &detailed) && detailed.StatusCode == http.StatusNotFound
+}
+
+// Recreate creates a resource from the properties returned by Get and waits until Azure is done. IDs in the properties
+// are rescoped with moved, which maps the IDs of moved resources to their IDs after the move.
+func Recreate(ctx context.Context, client resources.Client, azureID, apiVersion string, properties json.RawMessage, moved map[string]string) error {
+	var doc interface{}
+	if err := json.Unmarshal(properties, &doc); err != nil {
+		return fmt.Errorf("properties of %s cannot be parsed: %v", azureID, err)
+	}
+	data, err := json.Marshal(rescope(doc, moved))
+	if err != nil {
+		return err
+	}
+	var resource resources.GenericResource
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return fmt.Errorf("properties of %s cannot be parsed: %v", azureID, err)
+	}
+
+	future, err := client.CreateOrUpdateByID(ctx, azureID, apiVersion, resource)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, client.Client)
+}
+
+// rescope replaces the IDs of moved resources in a (nested) value, the most specific ID first.
+func rescope(value interface{}, moved map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		var from []string
+		for id := range moved {
+			from = append(from, id)
+		}
+		sort.Slice(from, func(i, k int) bool { return len(from[i]) > len(from[k]) })
+		for _, id := range from {
+			if rescoped, ok := azureid.Rescope(v, id, moved[id]); ok {
+				return rescoped
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = rescope(v[i], moved)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = rescope(v[k], moved)
+		}
+	}
+	return value
+}
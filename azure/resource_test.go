@@ -0,0 +1,62 @@
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	autorestazure "github.com/Azure/go-autorest/autorest/azure"
+)
+
+func TestRescope(t *testing.T) {
+	moved := map[string]string{
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app":                "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Web/sites/app",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Network/virtualNetworks/vnet": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Network/virtualNetworks/vnet",
+	}
+	var properties interface{}
+	json.Unmarshal([]byte(`{
+		"location": "westeurope",
+		"properties": {
+			"subnetResourceId": "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/MyResourceGroup/providers/Microsoft.Network/virtualNetworks/vnet/subnets/apps",
+			"swiftSupported": true,
+			"other": ["/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Network/virtualNetworks/vnet2"]
+		}
+	}`), &properties)
+
+	got := rescope(properties, moved)
+	wanted := map[string]interface{}{
+		"location": "westeurope",
+		"properties": map[string]interface{}{
+			"subnetResourceId": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Network/virtualNetworks/vnet/subnets/apps",
+			"swiftSupported":   true,
+			"other":            []interface{}{"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Network/virtualNetworks/vnet2"},
+		},
+	}
+	if !reflect.DeepEqual(got, wanted) {
+		t.Errorf("got %v wanted %v", got, wanted)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "Not found", err: autorest.DetailedError{StatusCode: http.StatusNotFound, Original: &autorestazure.RequestError{DetailedError: autorest.DetailedError{StatusCode: http.StatusNotFound}}}, want: true},
+		{name: "Detailed error only", err: autorest.DetailedError{StatusCode: http.StatusNotFound}, want: true},
+		{name: "Forbidden", err: autorest.DetailedError{StatusCode: http.StatusForbidden}},
+		{name: "Other error", err: errors.New("connection reset")},
+		{name: "No error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NotFound(tt.err); got != tt.want {
+				t.Errorf("got %v wanted %v", got, tt.want)
+			}
+		})
+	}
+}
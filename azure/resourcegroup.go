@@ -0,0 +1,57 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+)
+
+// Tags are the tags of a resource group to create, set as flag with one 'key=value' per tag.
+type Tags map[string]string
+
+func (t *Tags) String() string {
+	var tags []string
+	for k, v := range *t {
+		tags = append(tags, k+"="+v)
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, ",")
+}
+
+func (t *Tags) Set(value string) error {
+	idx := strings.Index(value, "=")
+	if idx < 1 {
+		return fmt.Errorf("no 'key=value' in arg: %s", value)
+	}
+
+	if *t == nil {
+		*t = Tags{}
+	}
+	(*t)[value[:idx]] = value[idx+1:]
+	return nil
+}
+
+// ResourceGroupExists reports whether the resource group exists in the subscription of the client.
+func ResourceGroupExists(ctx context.Context, client resources.GroupsClient, resourceGroup string) (bool, error) {
+	response, err := client.CheckExistence(ctx, resourceGroup)
+	if err != nil {
+		return false, err
+	}
+	return response.StatusCode != http.StatusNotFound, nil
+}
+
+// CreateResourceGroup creates the resource group, or updates its tags when it exists already.
+func CreateResourceGroup(ctx context.Context, client resources.GroupsClient, resourceGroup, location string, tags Tags) error {
+	group := resources.Group{Location: &location, Tags: map[string]*string{}}
+	for k := range tags {
+		v := tags[k]
+		group.Tags[k] = &v
+	}
+
+	_, err := client.CreateOrUpdate(ctx, resourceGroup, group)
+	return err
+}
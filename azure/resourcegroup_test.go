@@ -0,0 +1,27 @@
+package azure
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	var tags Tags
+	for _, value := range []string{"environment=production", "team=platform=core", "empty="} {
+		if err := tags.Set(value); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	wanted := Tags{"environment": "production", "team": "platform=core", "empty": ""}
+	if !reflect.DeepEqual(tags, wanted) {
+		t.Errorf("got %v wanted %v", tags, wanted)
+	}
+	if got := tags.String(); got != "empty=,environment=production,team=platform=core" {
+		t.Errorf("got %v wanted %v", got, "empty=,environment=production,team=platform=core")
+	}
+
+	if err := tags.Set("=production"); err == nil {
+		t.Errorf("expected an error for a tag without key")
+	}
+}
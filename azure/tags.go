@@ -0,0 +1,26 @@
+package azure
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+)
+
+// GetTags returns the tags of a resource as they are in Azure.
+func GetTags(ctx context.Context, client resources.TagsClient, azureID string) (map[string]string, error) {
+	result, err := client.GetAtScope(ctx, strings.TrimPrefix(azureID, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	if result.Properties != nil {
+		for key, value := range result.Properties.Tags {
+			if value != nil {
+				tags[key] = *value
+			}
+		}
+	}
+	return tags, nil
+}
@@ -0,0 +1,179 @@
+// Package azureid parses, compares and rewrites Azure Resource Manager IDs, like
+// /subscriptions/{subscription}/resourceGroups/{resourceGroup}/providers/Microsoft.Web/sites/{site}.
+// The keys `subscriptions`, `resourceGroups` and `providers` are matched case-insensitively, as ARM does.
+package azureid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ID is a parsed ARM ID of a subscription, resource group or resource.
+type ID struct {
+	SubscriptionID string
+	ResourceGroup  string
+	// Namespace is the resource provider of the resource, i.e. `Microsoft.Web`. It is empty for subscriptions and resource groups.
+	Namespace string
+	// Types and Names of the resource and its parents, i.e. [sites slots] and [app staging].
+	Types []string
+	Names []string
+	// Scope is the resource an extension resource is applied to, i.e. the key vault of a diagnostic setting.
+	Scope *ID
+}
+
+// Parse parses and validates an ARM ID. Extension resources, which are nested in another resource with an
+// additional `/providers/` segment, are parsed with their Scope.
+func Parse(s string) (ID, error) {
+	var id ID
+	if !strings.HasPrefix(s, "/") {
+		return id, fmt.Errorf("%q is not an Azure resource ID: it doesn't start with /", s)
+	}
+	segments := strings.Split(strings.TrimSuffix(s[1:], "/"), "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return id, fmt.Errorf("%q is not an Azure resource ID: it contains an empty segment", s)
+		}
+	}
+
+	i := 0
+	if strings.EqualFold(segments[i], "subscriptions") {
+		if i+1 >= len(segments) {
+			return id, fmt.Errorf("%q is not an Azure resource ID: the subscription is missing", s)
+		}
+		id.SubscriptionID = segments[i+1]
+		i += 2
+
+		if i < len(segments) && strings.EqualFold(segments[i], "resourceGroups") {
+			if i+1 >= len(segments) {
+				return id, fmt.Errorf("%q is not an Azure resource ID: the resource group is missing", s)
+			}
+			id.ResourceGroup = segments[i+1]
+			i += 2
+		}
+	}
+
+	for i < len(segments) {
+		if !strings.EqualFold(segments[i], "providers") || i+1 >= len(segments) {
+			return id, fmt.Errorf("%q is not an Azure resource ID: expected providers/{namespace} at %q", s, segments[i])
+		}
+		namespace := segments[i+1]
+		i += 2
+
+		// types and names alternate until the providers of an extension resource
+		var types, names []string
+		for i < len(segments) && !strings.EqualFold(segments[i], "providers") {
+			if i+1 >= len(segments) {
+				return id, fmt.Errorf("%q is not an Azure resource ID: the name of %s is missing", s, segments[i])
+			}
+			types = append(types, segments[i])
+			names = append(names, segments[i+1])
+			i += 2
+		}
+		if len(types) == 0 {
+			return id, fmt.Errorf("%q is not an Azure resource ID: the resource type of %s is missing", s, namespace)
+		}
+
+		if id.Namespace != "" {
+			scope := id
+			id = ID{SubscriptionID: scope.SubscriptionID, ResourceGroup: scope.ResourceGroup, Scope: &scope}
+		}
+		id.Namespace, id.Types, id.Names = namespace, types, names
+	}
+
+	if id.SubscriptionID == "" && id.Namespace == "" {
+		return id, fmt.Errorf("%q is not an Azure resource ID", s)
+	}
+	return id, nil
+}
+
+// String formats the ID with the keys as ARM formats them.
+func (id ID) String() string {
+	var b strings.Builder
+	if id.Scope != nil {
+		b.WriteString(id.Scope.String())
+	} else {
+		if id.SubscriptionID != "" {
+			b.WriteString("/subscriptions/" + id.SubscriptionID)
+		}
+		if id.ResourceGroup != "" {
+			b.WriteString("/resourceGroups/" + id.ResourceGroup)
+		}
+	}
+	if id.Namespace != "" {
+		b.WriteString("/providers/" + id.Namespace)
+		for i := range id.Types {
+			b.WriteString("/" + id.Types[i] + "/" + id.Names[i])
+		}
+	}
+	return b.String()
+}
+
+// Type returns the resource type, i.e. `Microsoft.Web/sites/slots`. For extension resources this is the type of the
+// extension. Subscriptions and resource groups have no type.
+func (id ID) Type() string {
+	if id.Namespace == "" {
+		return ""
+	}
+	return id.Namespace + "/" + strings.Join(id.Types, "/")
+}
+
+// Name returns the name of the resource itself.
+func (id ID) Name() string {
+	if len(id.Names) == 0 {
+		return ""
+	}
+	return id.Names[len(id.Names)-1]
+}
+
+// ResourceGroupID formats the ID of a resource group.
+func ResourceGroupID(subscriptionID, resourceGroup string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", subscriptionID, resourceGroup)
+}
+
+// Scope returns the subscription and resource group an ID starts with, without validating the rest of the ID. This
+// supports the IDs the azurerm provider composes which are not strictly ARM IDs. ok is false when the ID doesn't start
+// with a subscription.
+func Scope(s string) (subscriptionID, resourceGroup string, ok bool) {
+	segments := strings.SplitN(strings.TrimPrefix(s, "/"), "/", 5)
+	if !strings.HasPrefix(s, "/") || len(segments) < 2 || !strings.EqualFold(segments[0], "subscriptions") || segments[1] == "" {
+		return "", "", false
+	}
+	if len(segments) >= 4 && strings.EqualFold(segments[2], "resourceGroups") {
+		resourceGroup = segments[3]
+	}
+	return segments[1], resourceGroup, true
+}
+
+// LockScope splits the ID of a management lock in the ID it's applied to, a subscription, resource group or resource,
+// and the name of the lock. ok is false when the ID isn't a lock.
+func LockScope(s string) (scope, name string, ok bool) {
+	const locks = "/providers/microsoft.authorization/locks/"
+	i := strings.LastIndex(strings.ToLower(s), locks)
+	if i <= 0 {
+		return "", "", false
+	}
+	name = strings.TrimSuffix(s[i+len(locks):], "/")
+	if name == "" || strings.Contains(name, "/") {
+		return "", "", false
+	}
+	return s[:i], name, true
+}
+
+// Equal reports whether both IDs refer to the same resource, ignoring case like ARM does.
+func Equal(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "/"), strings.TrimSuffix(b, "/"))
+}
+
+// Rescope replaces the scope an ID starts with, i.e. the resource group of a resource which is moved. The scope is
+// matched case-insensitively on whole segments and the rest of the ID is kept as is. ok is false, and the ID is returned
+// unchanged, when the ID isn't within the scope.
+func Rescope(s, from, to string) (string, bool) {
+	from = strings.TrimSuffix(from, "/")
+	if from == "" || len(s) < len(from) || !strings.EqualFold(s[:len(from)], from) {
+		return s, false
+	}
+	if len(s) > len(from) && s[len(from)] != '/' {
+		return s, false
+	}
+	return strings.TrimSuffix(to, "/") + s[len(from):], true
+}
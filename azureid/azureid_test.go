@@ -0,0 +1,210 @@
+package azureid
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("Resource", func(t *testing.T) {
+		got, err := Parse("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app/slots/staging")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wanted := ID{
+			SubscriptionID: "00000000-0000-0000-0000-000000000000",
+			ResourceGroup:  "myresourcegroup",
+			Namespace:      "Microsoft.Web",
+			Types:          []string{"sites", "slots"},
+			Names:          []string{"app", "staging"},
+		}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+		if got.Type() != "Microsoft.Web/sites/slots" || got.Name() != "staging" {
+			t.Errorf("got %s and %s wanted Microsoft.Web/sites/slots and staging", got.Type(), got.Name())
+		}
+	})
+
+	t.Run("Lower case keys", func(t *testing.T) {
+		got, err := Parse("/SUBSCRIPTIONS/00000000-0000-0000-0000-000000000000/resourcegroups/myresourcegroup/Providers/Microsoft.Storage/storageAccounts/storageaccount1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wanted := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+		if got.String() != wanted {
+			t.Errorf("got %s wanted %s", got, wanted)
+		}
+	})
+
+	t.Run("Extension resource", func(t *testing.T) {
+		got, err := Parse("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.KeyVault/vaults/kv/providers/Microsoft.Authorization/locks/lock")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Type() != "Microsoft.Authorization/locks" || got.ResourceGroup != "myresourcegroup" || got.Scope == nil || got.Scope.Type() != "Microsoft.KeyVault/vaults" {
+			t.Errorf("got %#v, parsed as the wrong resource", got)
+		}
+	})
+
+	t.Run("Resource group", func(t *testing.T) {
+		got, err := Parse("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.ResourceGroup != "myresourcegroup" || got.Type() != "" {
+			t.Errorf("got %#v wanted a resource group", got)
+		}
+	})
+
+	t.Run("Subscription resource", func(t *testing.T) {
+		got, err := Parse("/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Authorization/roleDefinitions/reader")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.ResourceGroup != "" || got.Type() != "Microsoft.Authorization/roleDefinitions" {
+			t.Errorf("got %#v wanted a subscription resource", got)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, s := range []string{
+			"",
+			"/",
+			"https://example.blob.core.windows.net/container",
+			"subscriptions/00000000-0000-0000-0000-000000000000",
+			"/subscriptions/",
+			"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups",
+			"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web",
+			"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites",
+			"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/sites/app",
+			"/subscriptions/00000000-0000-0000-0000-000000000000//resourceGroups/myresourcegroup",
+		} {
+			if _, err := Parse(s); err == nil {
+				t.Errorf("expected an error for %q", s)
+			}
+		}
+	})
+}
+
+func TestScope(t *testing.T) {
+	tests := map[string][3]string{
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/myresourcegroup/providers/Microsoft.Web/sites/app": {"00000000-0000-0000-0000-000000000000", "myresourcegroup", "true"},
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup":                                   {"00000000-0000-0000-0000-000000000000", "myresourcegroup", "true"},
+		"/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Authorization/roleDefinitions/reader":         {"00000000-0000-0000-0000-000000000000", "", "true"},
+		"https://example.blob.core.windows.net/container":                                                                      {"", "", "false"},
+	}
+	for s, wanted := range tests {
+		subscriptionID, resourceGroup, ok := Scope(s)
+		got := [3]string{subscriptionID, resourceGroup, map[bool]string{true: "true", false: "false"}[ok]}
+		if got != wanted {
+			t.Errorf("got %v wanted %v for %s", got, wanted, s)
+		}
+	}
+}
+
+func TestLockScope(t *testing.T) {
+	tests := map[string][3]string{
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Authorization/locks/nodelete":                                   {"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup", "nodelete", "true"},
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app/providers/microsoft.authorization/locks/readonly": {"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app", "readonly", "true"},
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app":                                                  {"", "", "false"},
+		"/providers/Microsoft.Authorization/locks/": {"", "", "false"},
+	}
+	for s, wanted := range tests {
+		scope, name, ok := LockScope(s)
+		got := [3]string{scope, name, map[bool]string{true: "true", false: "false"}[ok]}
+		if got != wanted {
+			t.Errorf("got %v wanted %v for %s", got, wanted, s)
+		}
+	}
+}
+
+func TestRescope(t *testing.T) {
+	from := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup"
+	to := "/subscriptions/11111111-1111-1111-1111-111111111111/resourceGroups/myresourcegroup2"
+
+	tests := []struct {
+		id     string
+		wanted string
+		ok     bool
+	}{
+		{from + "/providers/Microsoft.Web/sites/app", to + "/providers/Microsoft.Web/sites/app", true},
+		{strings.ToLower(from) + "/providers/Microsoft.Web/sites/app", to + "/providers/Microsoft.Web/sites/app", true},
+		{from, to, true},
+		{from + "-other/providers/Microsoft.Web/sites/app", from + "-other/providers/Microsoft.Web/sites/app", false},
+		{"https://example.blob.core.windows.net/container", "https://example.blob.core.windows.net/container", false},
+	}
+	for _, test := range tests {
+		got, ok := Rescope(test.id, from, to)
+		if got != test.wanted || ok != test.ok {
+			t.Errorf("got %s, %t wanted %s, %t", got, ok, test.wanted, test.ok)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	if !Equal("/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/MyResourceGroup", "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/") {
+		t.Errorf("expected IDs differing in case to be equal")
+	}
+	if Equal("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup", "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2") {
+		t.Errorf("expected different IDs to differ")
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app/slots/staging")
+	f.Add("/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/myresourcegroup/providers/Microsoft.KeyVault/vaults/kv/providers/Microsoft.Authorization/locks/lock")
+	f.Add("/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Authorization/roleDefinitions/reader")
+	f.Add("/providers/Microsoft.Management/managementGroups/mg")
+	f.Add("https://example.blob.core.windows.net/container")
+	f.Fuzz(func(t *testing.T, s string) {
+		id, err := Parse(s)
+		if err != nil {
+			return
+		}
+
+		// formatting only changes the case of the keys, so the formatted ID parses to the same ID
+		formatted := id.String()
+		if !Equal(s, formatted) {
+			t.Errorf("%q is formatted as %q", s, formatted)
+		}
+		again, err := Parse(formatted)
+		if err != nil {
+			t.Fatalf("formatted %q doesn't parse: %v", formatted, err)
+		}
+		if !reflect.DeepEqual(again, id) {
+			t.Errorf("formatted %q parses as %#v instead of %#v", formatted, again, id)
+		}
+
+		if subscriptionID, resourceGroup, ok := Scope(s); id.SubscriptionID != "" && (!ok || subscriptionID != id.SubscriptionID || resourceGroup != id.ResourceGroup) {
+			t.Errorf("scope of %q is %s and %s instead of %s and %s", s, subscriptionID, resourceGroup, id.SubscriptionID, id.ResourceGroup)
+		}
+	})
+}
+
+func FuzzRescope(f *testing.F) {
+	f.Add("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app", "myresourcegroup", "myresourcegroup2")
+	f.Add("/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/MyResourceGroup", "myresourcegroup", "other")
+	f.Fuzz(func(t *testing.T, s, fromResourceGroup, toResourceGroup string) {
+		if strings.Contains(fromResourceGroup, "/") || strings.Contains(toResourceGroup, "/") || fromResourceGroup == "" || toResourceGroup == "" {
+			return
+		}
+		from := ResourceGroupID("00000000-0000-0000-0000-000000000000", fromResourceGroup)
+		to := ResourceGroupID("00000000-0000-0000-0000-000000000000", toResourceGroup)
+
+		rescoped, ok := Rescope(s, from, to)
+		if !ok {
+			if rescoped != s {
+				t.Errorf("%q is changed to %q without being rescoped", s, rescoped)
+			}
+			return
+		}
+
+		// moving back results in the same ID
+		back, ok := Rescope(rescoped, to, from)
+		if !ok || !Equal(back, s) {
+			t.Errorf("%q is rescoped to %q and back to %q", s, rescoped, back)
+		}
+	})
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aristosvo/aztfmove/azure"
+	"github.com/aristosvo/aztfmove/azureid"
+	"github.com/aristosvo/aztfmove/journal"
+	"github.com/aristosvo/aztfmove/state"
+)
+
+// execute runs all steps of the journal which are not done yet. Every step is recorded in the journal as soon as it
+// finishes, so after a failure the move can be finished with `aztfmove resume`.
+func execute(creds *azure.Credentials, tf state.Terraform, j *journal.Journal) {
+	ctx := context.Background()
+	section := ""
+	previous := -1
+	for i, step := range j.Steps {
+		if step.Status == journal.Done || step.Status == journal.Skipped {
+			continue
+		}
+
+		if s := stepSection(j.Steps, i); s != section {
+			printSection(s)
+			section = s
+		}
+
+		printStepStart(j.Steps, i, previous)
+		err := runStep(ctx, creds, tf, j, i)
+		if saveErr := j.Finish(i, err); saveErr != nil {
+			fmt.Printf("\n%s %v\n", Fata("Error:"), saveErr)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("\n%s %s failed: %v", Fata("Error:"), step, err)
+			printMoveErrorDetails(err)
+			if j.RolledBack {
+				fmt.Printf("\n\nAll steps are recorded in %s. Fix the issue above and run %s to finish the rollback.\n", j.Path(), Good("aztfmove rollback"))
+			} else {
+				fmt.Printf("\n\nAll steps are recorded in %s. Fix the issue above and run %s to finish the move.\n", j.Path(), Good("aztfmove resume"))
+			}
+			os.Exit(1)
+		}
+		printStepDone(step)
+		previous = i
+	}
+}
+
+func runStep(ctx context.Context, creds *azure.Credentials, tf state.Terraform, j *journal.Journal, i int) error {
+	step := j.Steps[i]
+	switch step.Kind {
+	case journal.Delete:
+		ctx, cancel := context.WithTimeout(ctx, 60*60*time.Second)
+		defer cancel()
+
+		subscriptionID, _, _ := azureid.Scope(step.AzureID)
+		client := creds.ResourcesClient(subscriptionID)
+		// the properties are recorded in the journal with the step, so the resource can be recreated after the move
+		if step.Properties == nil {
+			properties, err := azure.Get(ctx, client, step.AzureID, step.APIVersion)
+			switch {
+			case azure.NotFound(err):
+				// deleted by an earlier attempt which wasn't recorded, recreating it fails as nothing is captured
+				return nil
+			case err != nil:
+				return fmt.Errorf("properties of %s cannot be captured: %v", step.AzureID, err)
+			}
+			j.Steps[i].Properties = properties
+		}
+		if err := azure.Delete(ctx, client, step.AzureID, step.APIVersion); err != nil && !azure.NotFound(err) {
+			return err
+		}
+		return nil
+	case journal.Recreate:
+		ctx, cancel := context.WithTimeout(ctx, 60*60*time.Second)
+		defer cancel()
+
+		properties := j.Captured(step.Address)
+		if properties == nil {
+			return fmt.Errorf("properties of %s are not captured before it was deleted", step.Address)
+		}
+		subscriptionID, _, _ := azureid.Scope(step.AzureID)
+		return azure.Recreate(ctx, creds.ResourcesClient(subscriptionID), step.AzureID, step.APIVersion, properties, step.Moved)
+	case journal.Apply:
+		return tf.Apply(ctx, step.Addresses)
+	case journal.CreateResourceGroup:
+		return azure.CreateResourceGroup(ctx, creds.GroupsClient(step.SubscriptionID), step.ResourceGroup, step.Location, step.Tags)
+	case journal.RemoveLock:
+		subscriptionID, _, _ := azureid.Scope(step.Lock.ID)
+		return azure.DeleteLock(ctx, creds.LocksClient(subscriptionID), *step.Lock)
+	case journal.CreateLock:
+		subscriptionID, _, _ := azureid.Scope(step.Lock.ID)
+		return azure.CreateLock(ctx, creds.LocksClient(subscriptionID), *step.Lock)
+	case journal.Move:
+		ctx, cancel := context.WithTimeout(ctx, 60*60*time.Second)
+		defer cancel()
+
+		// Resources which are not in the source resource group anymore are moved already by an earlier attempt
+		client := creds.ResourcesClient(step.SubscriptionID)
+		present, err := azure.ListResourceGroup(ctx, client, step.ResourceGroup)
+		if err != nil {
+			return err
+		}
+		var azureIDs []string
+		for _, id := range step.AzureIDs {
+			if containsID(present, id) {
+				azureIDs = append(azureIDs, id)
+			}
+		}
+		if len(azureIDs) == 0 {
+			return nil
+		}
+		return azure.Move(ctx, client, step.ResourceGroup, azureIDs, step.TargetResourceGroupID)
+	case journal.Remove:
+		return tf.Remove(ctx, step.Address)
+	case journal.Import:
+		return tf.Import(ctx, step.Address, step.AzureID)
+	case journal.Push:
+		return tf.Push(ctx, step.Path, true)
+	case journal.Rewrite:
+		return rewriteState(ctx, tf, step)
+	case journal.ImportBlocks:
+		if err := os.WriteFile(step.Path, state.ImportBlocks(step.IDs), 0644); err != nil {
+			return fmt.Errorf("import blocks cannot be written: %v", err)
+		}
+		return nil
+	case journal.RemoveImportBlocks:
+		if err := os.Remove(step.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("import blocks cannot be removed: %v", err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown step %q", step.Kind)
+}
+
+// rewriteState corrects the moved instances in the pulled state and pushes the result. The push is not forced, so
+// Terraform refuses it when the state changed in the meantime.
+func rewriteState(ctx context.Context, tf state.Terraform, step journal.Step) error {
+	tfstate, err := tf.Pull(ctx)
+	if err != nil {
+		return err
+	}
+	rewritten, err := tfstate.Rewrite(step.IDs, step.References)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(".", "terraform.tfstate.*.aztfmove.rewrite")
+	if err != nil {
+		return fmt.Errorf("rewritten Terraform state cannot be written: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(rewritten.Raw()); err != nil {
+		f.Close()
+		return fmt.Errorf("rewritten Terraform state cannot be written: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("rewritten Terraform state cannot be written: %v", err)
+	}
+
+	return tf.Push(ctx, f.Name(), false)
+}
+
+// stepSection groups the steps for printing: deleting blocking resources, removing them from the state, moving the
+// resources in Azure and correcting the state.
+func stepSection(steps []journal.Step, i int) string {
+	switch steps[i].Kind {
+	case journal.Remove:
+		if i+1 < len(steps) && steps[i+1].Kind == journal.Import && steps[i+1].Address == steps[i].Address {
+			return "correct"
+		}
+		return "remove"
+	case journal.Import:
+		return "correct"
+	}
+	return string(steps[i].Kind)
+}
+
+func printSection(section string) {
+	switch section {
+	case "delete":
+		fmt.Print(Azure("\nBlocking resources will be deleted in Azure."))
+	case "remove":
+		fmt.Print(Terraform("\n\nResources in Terraform state will be removed:"))
+	case "move":
+		fmt.Print(Azure("\nResources are on the move to the specified resource group."))
+		fmt.Printf("\nIt can take some time before this is done, don't panic!")
+	case "correct":
+		fmt.Print(Terraform("\n\nResources in Terraform state are enhanced:"))
+	case "push":
+		fmt.Print(Terraform("\n\nTerraform state is restored from the backup:"))
+	case "rewrite":
+		fmt.Print(Terraform("\n\nResources in Terraform state are rewritten:"))
+	case "import-blocks":
+		fmt.Print(Terraform("\n\nImport blocks are written for the removed resources:"))
+	case "remove-import-blocks":
+		fmt.Print(Terraform("\n\nImport blocks of the move are removed:"))
+	case "recreate":
+		fmt.Print(Azure("\n\nBlocking resources are recreated in Azure:"))
+	case "apply":
+		fmt.Print(Terraform("\n\nBlocking resources are recreated by Terraform:"))
+	case "create-resource-group":
+		fmt.Print(Azure("\nThe target resource group is created:"))
+	case "remove-lock":
+		fmt.Print(Azure("\nManagement locks are removed:"))
+	case "create-lock":
+		fmt.Print(Azure("\n\nManagement locks are created again:"))
+	}
+}
+
+// printStepStart prints the subject of step i, previous is the step which ran right before it in this run.
+func printStepStart(steps []journal.Step, i int, previous int) {
+	switch step := steps[i]; step.Kind {
+	case journal.Delete, journal.Recreate:
+		fmt.Println("\n -", step.AzureID)
+	case journal.Apply:
+		for _, address := range step.Addresses {
+			fmt.Print("\n - ", address)
+		}
+		fmt.Println()
+	case journal.RemoveLock, journal.CreateLock:
+		fmt.Println("\n -", step.Lock)
+	case journal.CreateResourceGroup:
+		fmt.Printf("\n - %s in %s\n", azureid.ResourceGroupID(step.SubscriptionID, step.ResourceGroup), step.Location)
+	case journal.Move:
+		fmt.Printf("\n - from resource group %s (subscription %s)", step.ResourceGroup, step.SubscriptionID)
+	case journal.Remove:
+		fmt.Println("\n -", step.Address)
+	case journal.Push:
+		fmt.Println("\n -", step.Path)
+	case journal.ImportBlocks, journal.RemoveImportBlocks:
+		fmt.Println("\n -", step.Path)
+	case journal.Rewrite:
+		var addresses []string
+		for address := range step.IDs {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+		for _, address := range addresses {
+			fmt.Printf("\n - %s: [id=%s]", address, step.IDs[address])
+		}
+		for _, ref := range step.References {
+			fmt.Printf("\n - %s: %s -> %s", ref, ref.Value, ref.FutureValue)
+		}
+		fmt.Println()
+	case journal.Import:
+		// the address is printed already when the instance is removed right before
+		if previous == -1 || previous != i-1 || steps[previous].Kind != journal.Remove || steps[previous].Address != step.Address {
+			fmt.Println("\n -", step.Address)
+		}
+	}
+}
+
+func printStepDone(step journal.Step) {
+	switch step.Kind {
+	case journal.Delete:
+		fmt.Printf("\t✓ Deleted")
+	case journal.Move:
+		fmt.Printf("\t✓ Moved")
+	case journal.Remove:
+		fmt.Printf("\t✓ Removed")
+	case journal.Import:
+		fmt.Printf("\t✓ Imported")
+	case journal.Push:
+		fmt.Printf("\t✓ Pushed")
+	case journal.Rewrite:
+		fmt.Printf("\t✓ Rewritten and pushed")
+	case journal.ImportBlocks:
+		fmt.Printf("\t✓ Written")
+	case journal.RemoveImportBlocks:
+		fmt.Printf("\t✓ Removed")
+	case journal.Recreate:
+		fmt.Printf("\t✓ Recreated")
+	case journal.Apply:
+		fmt.Printf("\t✓ Applied")
+	case journal.RemoveLock:
+		fmt.Printf("\t✓ Removed")
+	case journal.CreateLock, journal.CreateResourceGroup:
+		fmt.Printf("\t✓ Created")
+	}
+}
+
+func containsID(ids []string, id string) bool {
+	for _, v := range ids {
+		if azureid.Equal(v, id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,531 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aristosvo/aztfmove/azure"
+	"github.com/aristosvo/aztfmove/azureid"
+	"github.com/aristosvo/aztfmove/state"
+)
+
+const version = 1
+
+// DefaultPath is where the journal is written when no other path is specified.
+const DefaultPath = "aztfmove.journal.json"
+
+// Kind is the type of action of a step.
+type Kind string
+
+const (
+	// Delete deletes a resource blocking the move in Azure.
+	Delete Kind = "delete"
+	// Move moves resources from one source resource group in Azure.
+	Move Kind = "move"
+	// Remove removes a resource instance from the Terraform state.
+	Remove Kind = "remove"
+	// Import imports a resource instance in the Terraform state.
+	Import Kind = "import"
+	// Push replaces the Terraform state with a backup.
+	Push Kind = "push"
+	// Rewrite corrects the moved instances in the Terraform state at once, instead of a Remove and Import per instance.
+	Rewrite Kind = "rewrite"
+	// ImportBlocks writes Terraform import blocks for the removed instances, instead of importing them.
+	ImportBlocks Kind = "import-blocks"
+	// RemoveImportBlocks removes the written import blocks when the move is rolled back, these import the moved IDs.
+	RemoveImportBlocks Kind = "remove-import-blocks"
+	// Recreate creates a deleted blocking resource again in Azure, from the properties captured before it was deleted.
+	Recreate Kind = "recreate"
+	// Apply recreates deleted blocking resources with a targeted `terraform apply`.
+	Apply Kind = "apply"
+	// RemoveLock removes a management lock which blocks the move.
+	RemoveLock Kind = "remove-lock"
+	// CreateLock creates a removed management lock again, on the scope after the move.
+	CreateLock Kind = "create-lock"
+	// CreateResourceGroup creates the target resource group which doesn't exist yet.
+	CreateResourceGroup Kind = "create-resource-group"
+)
+
+// Recreation is how deleted blocking resources are created again after the move.
+type Recreation string
+
+const (
+	// RecreateNone leaves recreating the blocking resources to the next `terraform apply`.
+	RecreateNone Recreation = "none"
+	// RecreateTerraform runs `terraform apply -target` for the blocking resources.
+	RecreateTerraform Recreation = "terraform"
+	// RecreateARM creates the blocking resources through ARM and imports them.
+	RecreateARM Recreation = "arm"
+)
+
+type Status string
+
+const (
+	Pending Status = "pending"
+	Done    Status = "done"
+	Failed  Status = "failed"
+	// Skipped steps won't run anymore, as the move is rolled back.
+	Skipped Status = "skipped"
+)
+
+// Step is a single action of a move, recorded with enough detail to run it again.
+type Step struct {
+	Kind   Kind   `json:"kind"`
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// Address is the Terraform address of the instance to remove or import.
+	Address string `json:"address,omitempty"`
+	// AzureID is the resource to delete or recreate, or the resource ID to import.
+	AzureID string `json:"azure_id,omitempty"`
+	// APIVersion is the API version to delete or recreate the resource with.
+	APIVersion string `json:"api_version,omitempty"`
+	// Properties of a resource to delete are captured before it's deleted, to recreate it after the move.
+	Properties json.RawMessage `json:"properties,omitempty"`
+	// Moved maps the IDs of the moved resources to their IDs after the move, to rescope the properties to recreate.
+	Moved map[string]string `json:"moved,omitempty"`
+	// Addresses are the instances to apply.
+	Addresses []string `json:"addresses,omitempty"`
+	// Lock is the management lock to remove or create.
+	Lock *azure.Lock `json:"lock,omitempty"`
+
+	// SubscriptionID, ResourceGroup, AzureIDs and TargetResourceGroupID describe a move.
+	SubscriptionID        string   `json:"subscription_id,omitempty"`
+	ResourceGroup         string   `json:"resource_group,omitempty"`
+	AzureIDs              []string `json:"azure_ids,omitempty"`
+	TargetResourceGroupID string   `json:"target_resource_group_id,omitempty"`
+	// Location and Tags describe the resource group to create, in SubscriptionID and named ResourceGroup.
+	Location string     `json:"location,omitempty"`
+	Tags     azure.Tags `json:"tags,omitempty"`
+
+	// Path is the state file to push or the configuration file to write the import blocks to.
+	Path string `json:"path,omitempty"`
+
+	// IDs maps the address of every instance to rewrite or import to its ID after the move.
+	IDs map[string]string `json:"ids,omitempty"`
+	// References are the other attributes to rewrite, see state.References.
+	References []state.Reference `json:"references,omitempty"`
+}
+
+func (s Step) String() string {
+	switch s.Kind {
+	case Delete:
+		return fmt.Sprintf("delete %s", s.AzureID)
+	case Move:
+		return fmt.Sprintf("move %d resource(s) from %s to %s", len(s.AzureIDs), azureid.ResourceGroupID(s.SubscriptionID, s.ResourceGroup), s.TargetResourceGroupID)
+	case Remove:
+		return fmt.Sprintf("terraform state rm '%s'", s.Address)
+	case Import:
+		return fmt.Sprintf("terraform import '%s' '%s'", s.Address, s.AzureID)
+	case Push:
+		return fmt.Sprintf("terraform state push -force '%s'", s.Path)
+	case Rewrite:
+		return fmt.Sprintf("rewrite %d instance(s) in the Terraform state to %s", len(s.IDs), s.TargetResourceGroupID)
+	case ImportBlocks:
+		return fmt.Sprintf("write import blocks for %d instance(s) to '%s'", len(s.IDs), s.Path)
+	case RemoveImportBlocks:
+		return fmt.Sprintf("remove import blocks '%s'", s.Path)
+	case Recreate:
+		return fmt.Sprintf("recreate %s", s.AzureID)
+	case Apply:
+		return fmt.Sprintf("terraform apply -target='%s'", strings.Join(s.Addresses, "' -target='"))
+	case RemoveLock:
+		return fmt.Sprintf("remove lock %s", s.Lock)
+	case CreateLock:
+		return fmt.Sprintf("create lock %s", s.Lock)
+	case CreateResourceGroup:
+		return fmt.Sprintf("create resource group %s in %s", azureid.ResourceGroupID(s.SubscriptionID, s.ResourceGroup), s.Location)
+	}
+	return string(s.Kind)
+}
+
+// Journal records every step of a move on disk, so a partially failed move can be resumed.
+type Journal struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// Backup is the state file pulled before the Terraform state is changed.
+	Backup     string `json:"backup,omitempty"`
+	RolledBack bool   `json:"rolled_back,omitempty"`
+	Steps      []Step `json:"steps"`
+
+	path string
+}
+
+func New(path string) *Journal {
+	return &Journal{Version: version, Created: time.Now().UTC(), path: path}
+}
+
+// Load reads a journal written by an earlier run.
+func Load(path string) (*Journal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("journal %s cannot be read: %v", path, err)
+	}
+
+	j := &Journal{path: path}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("journal %s cannot be parsed: %v", path, err)
+	}
+	if j.Version != version {
+		return nil, fmt.Errorf("journal %s has version %d, only version %d is supported", path, j.Version, version)
+	}
+	return j, nil
+}
+
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Plan adds the steps to move the selected resources: deleting blocking resources, moving the resources per source
+// resource group and correcting the Terraform state for every instance. Instances which can't be imported are only
+// removed, Terraform creates these again.
+func (j *Journal) Plan(config *state.Config, resourceInstances state.ResourcesInstanceSummary, targetSubscriptionID, targetResourceGroup string) {
+	j.planMove(config, resourceInstances, targetSubscriptionID, targetResourceGroup)
+
+	toCorrect := resourceInstances.ToCorrectInTFState(config)
+	for _, r := range resourceInstances {
+		if _, ok := toCorrect[r.TerraformID]; !ok {
+			continue
+		}
+		j.Steps = append(j.Steps, Step{Kind: Remove, Status: Pending, Address: r.TerraformID})
+		if r.Importable(config) {
+			j.Steps = append(j.Steps, Step{Kind: Import, Status: Pending, Address: r.TerraformID, AzureID: r.FutureAzureID})
+		}
+	}
+}
+
+// PlanRewrite adds the same steps as Plan, except the Terraform state is corrected with a single Rewrite step, which
+// rewrites the references as well.
+func (j *Journal) PlanRewrite(config *state.Config, resourceInstances state.ResourcesInstanceSummary, references []state.Reference, targetSubscriptionID, targetResourceGroup string) {
+	j.planMove(config, resourceInstances, targetSubscriptionID, targetResourceGroup)
+
+	if toCorrect := resourceInstances.ToCorrectInTFState(config); len(toCorrect) > 0 {
+		j.Steps = append(j.Steps, Step{
+			Kind:                  Rewrite,
+			Status:                Pending,
+			IDs:                   toCorrect,
+			References:            references,
+			TargetResourceGroupID: azureid.ResourceGroupID(targetSubscriptionID, targetResourceGroup),
+		})
+	}
+}
+
+// PlanImportBlocks adds the same steps as Plan, except the instances are only removed from the Terraform state. The
+// import blocks written to path import them again with the next `terraform apply`.
+func (j *Journal) PlanImportBlocks(config *state.Config, resourceInstances state.ResourcesInstanceSummary, targetSubscriptionID, targetResourceGroup, path string) {
+	j.planMove(config, resourceInstances, targetSubscriptionID, targetResourceGroup)
+
+	toCorrect := resourceInstances.ToCorrectInTFState(config)
+	for _, r := range resourceInstances {
+		if _, ok := toCorrect[r.TerraformID]; ok {
+			j.Steps = append(j.Steps, Step{Kind: Remove, Status: Pending, Address: r.TerraformID})
+		}
+	}
+	if toImport := resourceInstances.ToImport(config); len(toImport) > 0 {
+		j.Steps = append(j.Steps, Step{Kind: ImportBlocks, Status: Pending, Path: path, IDs: toImport})
+	}
+}
+
+// PlanRecreate adds the steps to create the deleted blocking resources again after the move, which run after the
+// Terraform state is corrected.
+func (j *Journal) PlanRecreate(config *state.Config, resourceInstances state.ResourcesInstanceSummary, recreation Recreation) {
+	tfIDs, _ := resourceInstances.BlockingMovement(config)
+	if len(tfIDs) == 0 {
+		return
+	}
+
+	switch recreation {
+	case RecreateTerraform:
+		j.Steps = append(j.Steps, Step{Kind: Apply, Status: Pending, Addresses: tfIDs})
+	case RecreateARM:
+		moved := movedIDs(config, resourceInstances)
+		for _, r := range resourceInstances {
+			if r.Category(config) != state.Blocking {
+				continue
+			}
+			j.Steps = append(j.Steps,
+				Step{Kind: Recreate, Status: Pending, Address: r.TerraformID, AzureID: r.FutureAzureID, APIVersion: r.DeleteAPIVersion(config), Moved: moved},
+				Step{Kind: Import, Status: Pending, Address: r.TerraformID, AzureID: r.FutureAzureID},
+			)
+		}
+	}
+}
+
+// PlanLocks adds the steps to remove the management locks before anything is deleted or moved, and to create them
+// again right after the move in Azure. Locks on moved resources are created on their ID after the move.
+func (j *Journal) PlanLocks(config *state.Config, locks []azure.Lock, resourceInstances state.ResourcesInstanceSummary) {
+	if len(locks) == 0 {
+		return
+	}
+
+	rescoped := RescopeLocks(config, locks, resourceInstances)
+	var remove, create []Step
+	for i := range locks {
+		remove = append(remove, Step{Kind: RemoveLock, Status: Pending, Lock: &locks[i]})
+		create = append(create, Step{Kind: CreateLock, Status: Pending, Lock: &rescoped[i]})
+	}
+
+	// the locks are back before the Terraform state is corrected, so locks in the state are imported on their new scope
+	after := 0
+	for i, step := range j.Steps {
+		if step.Kind == Move || step.Kind == Delete {
+			after = i + 1
+		}
+	}
+	steps := append(remove, j.Steps[:after]...)
+	steps = append(steps, create...)
+	j.Steps = append(steps, j.Steps[after:]...)
+}
+
+// PlanCreateResourceGroup adds the step to create the target resource group before anything else. When address is set,
+// the resource group is imported in Terraform as well, or an import block is written for it when import blocks are
+// written for the moved instances.
+func (j *Journal) PlanCreateResourceGroup(subscriptionID, resourceGroup, location string, tags azure.Tags, address string) {
+	steps := []Step{{Kind: CreateResourceGroup, Status: Pending, SubscriptionID: subscriptionID, ResourceGroup: resourceGroup, Location: location, Tags: tags}}
+	if address != "" {
+		id := azureid.ResourceGroupID(subscriptionID, resourceGroup)
+		imported := false
+		for i := range j.Steps {
+			if j.Steps[i].Kind == ImportBlocks {
+				j.Steps[i].IDs[address] = id
+				imported = true
+			}
+		}
+		if !imported {
+			steps = append(steps, Step{Kind: Import, Status: Pending, Address: address, AzureID: id})
+		}
+	}
+	j.Steps = append(steps, j.Steps...)
+}
+
+// RescopeLocks returns the locks as they are created again after the move: locks on moved resources get the ID after
+// the move, locks on resource groups stay where they are.
+func RescopeLocks(config *state.Config, locks []azure.Lock, resourceInstances state.ResourcesInstanceSummary) []azure.Lock {
+	moved := movedIDs(config, resourceInstances)
+	rescoped := make([]azure.Lock, len(locks))
+	for i, lock := range locks {
+		lock.ID = rescope(lock.ID, moved)
+		rescoped[i] = lock
+	}
+	return rescoped
+}
+
+// movedIDs maps the IDs of the resources which are moved in Azure, or along with them, to their IDs after the move.
+func movedIDs(config *state.Config, resourceInstances state.ResourcesInstanceSummary) map[string]string {
+	moved := map[string]string{}
+	for _, r := range resourceInstances {
+		if category := r.Category(config); (category == state.Movable || category == state.OnlyMovedInTF) && r.AzureID != r.FutureAzureID {
+			moved[r.AzureID] = r.FutureAzureID
+		}
+	}
+	return moved
+}
+
+// rescope replaces the ID of a moved resource an ID starts with, the most specific ID first.
+func rescope(id string, moved map[string]string) string {
+	from := ""
+	for m := range moved {
+		if _, ok := azureid.Rescope(id, m, moved[m]); ok && len(m) > len(from) {
+			from = m
+		}
+	}
+	if from == "" {
+		return id
+	}
+	rescoped, _ := azureid.Rescope(id, from, moved[from])
+	return rescoped
+}
+
+// Captured returns the properties of the instance captured before it was deleted.
+func (j *Journal) Captured(address string) json.RawMessage {
+	for _, step := range j.Steps {
+		if step.Kind == Delete && step.Address == address {
+			return step.Properties
+		}
+	}
+	return nil
+}
+
+// planMove adds the steps to delete blocking resources and to move the resources in Azure.
+func (j *Journal) planMove(config *state.Config, resourceInstances state.ResourcesInstanceSummary, targetSubscriptionID, targetResourceGroup string) {
+	var blocking []state.ResourceInstanceSummary
+	for _, r := range resourceInstances {
+		if r.Category(config) == state.Blocking {
+			blocking = append(blocking, r)
+		}
+	}
+	for _, r := range blocking {
+		j.Steps = append(j.Steps, Step{Kind: Delete, Status: Pending, Address: r.TerraformID, AzureID: r.AzureID, APIVersion: r.DeleteAPIVersion(config)})
+	}
+	for _, r := range blocking {
+		j.Steps = append(j.Steps, Step{Kind: Remove, Status: Pending, Address: r.TerraformID})
+	}
+
+	targetResourceGroupID := azureid.ResourceGroupID(targetSubscriptionID, targetResourceGroup)
+	for _, group := range resourceInstances.MoveGroups(config) {
+		j.Steps = append(j.Steps, Step{
+			Kind:                  Move,
+			Status:                Pending,
+			SubscriptionID:        group.SubscriptionID,
+			ResourceGroup:         group.ResourceGroup,
+			AzureIDs:              group.AzureIDs,
+			TargetResourceGroupID: targetResourceGroupID,
+		})
+	}
+}
+
+// Save writes the journal to disk. The file is replaced at once, so a crash never leaves a partially written journal.
+func (j *Journal) Save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("journal %s cannot be written: %v", j.path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("journal %s cannot be written: %v", j.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("journal %s cannot be written: %v", j.path, err)
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return fmt.Errorf("journal %s cannot be written: %v", j.path, err)
+	}
+	return nil
+}
+
+// Finish marks step i as done and saves the journal, or as failed when err is not nil.
+func (j *Journal) Finish(i int, err error) error {
+	if err != nil {
+		j.Steps[i].Status = Failed
+		j.Steps[i].Error = err.Error()
+	} else {
+		j.Steps[i].Status = Done
+		j.Steps[i].Error = ""
+	}
+	return j.Save()
+}
+
+// Complete reports whether all steps are done.
+func (j *Journal) Complete() bool {
+	for _, step := range j.Steps {
+		if step.Status != Done && step.Status != Skipped {
+			return false
+		}
+	}
+	return true
+}
+
+// Rollback skips the steps of the move which didn't run yet and adds the steps to undo it: moving the resources back
+// to their original resource groups, latest moved first, restoring the removed management locks and pushing the state
+// backup.
+// Deleted blocking resources can't be restored, these are recreated by Terraform from the restored state.
+func (j *Journal) Rollback() error {
+	if j.Backup == "" {
+		return fmt.Errorf("journal %s contains no Terraform state backup, the move can't be rolled back", j.path)
+	}
+
+	// locks created on the moved resources would block moving them back, the removed locks are created again afterwards
+	var steps, locks, files []Step
+	for _, step := range j.Steps {
+		if step.Status == Pending || step.Status == Skipped {
+			continue
+		}
+		switch step.Kind {
+		case CreateLock:
+			steps = append(steps, Step{Kind: RemoveLock, Status: Pending, Lock: step.Lock})
+		case RemoveLock:
+			locks = append(locks, Step{Kind: CreateLock, Status: Pending, Lock: step.Lock})
+		case ImportBlocks:
+			// the import blocks would import the IDs after the move in the restored state
+			files = append(files, Step{Kind: RemoveImportBlocks, Status: Pending, Path: step.Path})
+		}
+	}
+
+	for i := len(j.Steps) - 1; i >= 0; i-- {
+		step := j.Steps[i]
+		if step.Kind != Move || step.Status == Pending || step.Status == Skipped {
+			continue
+		}
+
+		sourceResourceGroupID := azureid.ResourceGroupID(step.SubscriptionID, step.ResourceGroup)
+		var azureIDs []string
+		for _, id := range step.AzureIDs {
+			rescoped, _ := azureid.Rescope(id, sourceResourceGroupID, step.TargetResourceGroupID)
+			azureIDs = append(azureIDs, rescoped)
+		}
+
+		target, err := azureid.Parse(step.TargetResourceGroupID)
+		if err != nil {
+			return fmt.Errorf("move in journal %s can't be rolled back: %v", j.path, err)
+		}
+		steps = append(steps, Step{
+			Kind:                  Move,
+			Status:                Pending,
+			SubscriptionID:        target.SubscriptionID,
+			ResourceGroup:         target.ResourceGroup,
+			AzureIDs:              azureIDs,
+			TargetResourceGroupID: sourceResourceGroupID,
+		})
+	}
+
+	for i, step := range j.Steps {
+		if step.Status != Done {
+			j.Steps[i].Status = Skipped
+		}
+	}
+
+	j.Steps = append(j.Steps, steps...)
+	j.Steps = append(j.Steps, locks...)
+	j.Steps = append(j.Steps, Step{Kind: Push, Status: Pending, Path: j.Backup})
+	j.Steps = append(j.Steps, files...)
+	j.RolledBack = true
+	return nil
+}
+
+// Reconcile marks the Terraform steps which are already reflected in the state as done. This covers steps which
+// succeeded while the journal could not be saved anymore, so resuming doesn't remove or import an instance twice.
+func (j *Journal) Reconcile(tfstate state.TerraformState) {
+	for i, step := range j.Steps {
+		if step.Status == Done {
+			continue
+		}
+
+		instance, found := tfstate.Instance(step.Address)
+		switch step.Kind {
+		case Remove:
+			if !found {
+				j.Steps[i].Status = Done
+				continue
+			}
+			// the instance is removed and imported already when the next step imports the same ID
+			if i+1 < len(j.Steps) && j.Steps[i+1].Kind == Import && j.Steps[i+1].Address == step.Address && azureid.Equal(instance.Attributes.ID, j.Steps[i+1].AzureID) {
+				j.Steps[i].Status = Done
+			}
+		case Import:
+			if found && azureid.Equal(instance.Attributes.ID, step.AzureID) {
+				j.Steps[i].Status = Done
+			}
+		case Rewrite:
+			rewritten := true
+			for address, id := range step.IDs {
+				if instance, found := tfstate.Instance(address); !found || !azureid.Equal(instance.Attributes.ID, id) {
+					rewritten = false
+				}
+			}
+			if rewritten {
+				j.Steps[i].Status = Done
+			}
+		}
+	}
+}
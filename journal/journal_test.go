@@ -0,0 +1,444 @@
+package journal
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aristosvo/aztfmove/azure"
+	"github.com/aristosvo/aztfmove/state"
+)
+
+var summary = state.ResourcesInstanceSummary{
+	{
+		AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app/networkConfig/virtualNetwork",
+		TerraformID:    "azurerm_app_service_virtual_network_swift_connection.app",
+		FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Web/sites/app/networkConfig/virtualNetwork",
+		Type:           "azurerm_app_service_virtual_network_swift_connection",
+		SubscriptionID: "00000000-0000-0000-0000-000000000000",
+		ResourceGroup:  "myresourcegroup",
+	},
+	{
+		AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app",
+		TerraformID:    "azurerm_app_service.app",
+		FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Web/sites/app",
+		Type:           "azurerm_app_service",
+		SubscriptionID: "00000000-0000-0000-0000-000000000000",
+		ResourceGroup:  "myresourcegroup",
+	},
+}
+
+func TestPlan(t *testing.T) {
+	config := state.DefaultConfig()
+	j := New("journal.json")
+	j.Plan(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+
+	wanted := []Step{
+		{Kind: Delete, Status: Pending, Address: summary[0].TerraformID, AzureID: summary[0].AzureID, APIVersion: "2021-02-01"},
+		{Kind: Remove, Status: Pending, Address: summary[0].TerraformID},
+		{
+			Kind:                  Move,
+			Status:                Pending,
+			SubscriptionID:        "00000000-0000-0000-0000-000000000000",
+			ResourceGroup:         "myresourcegroup",
+			AzureIDs:              []string{summary[1].AzureID},
+			TargetResourceGroupID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2",
+		},
+		{Kind: Remove, Status: Pending, Address: summary[1].TerraformID},
+		{Kind: Import, Status: Pending, Address: summary[1].TerraformID, AzureID: summary[1].FutureAzureID},
+	}
+	if !reflect.DeepEqual(j.Steps, wanted) {
+		t.Errorf("got %v wanted %v", j.Steps, wanted)
+	}
+}
+
+func TestPlanRecreate(t *testing.T) {
+	config := state.DefaultConfig()
+	t.Run("Terraform", func(t *testing.T) {
+		j := New("journal.json")
+		j.PlanRecreate(config, summary, RecreateTerraform)
+
+		wanted := []Step{{Kind: Apply, Status: Pending, Addresses: []string{summary[0].TerraformID}}}
+		if !reflect.DeepEqual(j.Steps, wanted) {
+			t.Errorf("got %v wanted %v", j.Steps, wanted)
+		}
+	})
+
+	t.Run("ARM", func(t *testing.T) {
+		j := New("journal.json")
+		j.Plan(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+		j.PlanRecreate(config, summary, RecreateARM)
+
+		wanted := []Step{
+			{
+				Kind:       Recreate,
+				Status:     Pending,
+				Address:    summary[0].TerraformID,
+				AzureID:    summary[0].FutureAzureID,
+				APIVersion: "2021-02-01",
+				Moved:      map[string]string{summary[1].AzureID: summary[1].FutureAzureID},
+			},
+			{Kind: Import, Status: Pending, Address: summary[0].TerraformID, AzureID: summary[0].FutureAzureID},
+		}
+		if len(j.Steps) != 7 || !reflect.DeepEqual(j.Steps[5:], wanted) {
+			t.Errorf("got %v wanted %v as last of 7 steps", j.Steps, wanted)
+		}
+
+		j.Steps[0].Properties = []byte(`{"location":"westeurope"}`)
+		if got := string(j.Captured(summary[0].TerraformID)); got != `{"location":"westeurope"}` {
+			t.Errorf("got %v wanted %v", got, `{"location":"westeurope"}`)
+		}
+	})
+
+	t.Run("None", func(t *testing.T) {
+		j := New("journal.json")
+		j.PlanRecreate(config, summary, RecreateNone)
+		if len(j.Steps) != 0 {
+			t.Errorf("got %v wanted no steps", j.Steps)
+		}
+	})
+}
+
+func TestPlanLocks(t *testing.T) {
+	config := state.DefaultConfig()
+	locks := []azure.Lock{
+		{ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Authorization/locks/group", Level: "CanNotDelete"},
+		{ID: summary[1].AzureID + "/providers/Microsoft.Authorization/locks/app", Level: "ReadOnly", Notes: "production"},
+	}
+	rescoped := azure.Lock{ID: summary[1].FutureAzureID + "/providers/Microsoft.Authorization/locks/app", Level: "ReadOnly", Notes: "production"}
+
+	j := New(filepath.Join(t.TempDir(), DefaultPath))
+	j.Plan(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+	j.PlanLocks(config, locks, summary)
+
+	var got []Kind
+	for _, step := range j.Steps {
+		got = append(got, step.Kind)
+	}
+	wanted := []Kind{RemoveLock, RemoveLock, Delete, Remove, Move, CreateLock, CreateLock, Remove, Import}
+	if !reflect.DeepEqual(got, wanted) {
+		t.Errorf("got %v wanted %v", got, wanted)
+	}
+	if !reflect.DeepEqual(*j.Steps[5].Lock, locks[0]) {
+		t.Errorf("got %v wanted %v", *j.Steps[5].Lock, locks[0])
+	}
+	if !reflect.DeepEqual(*j.Steps[6].Lock, rescoped) {
+		t.Errorf("got %v wanted %v", *j.Steps[6].Lock, rescoped)
+	}
+
+	t.Run("Rollback", func(t *testing.T) {
+		j.Backup = "terraform.tfstate.backup"
+		for i := 0; i < 7; i++ {
+			j.Finish(i, nil)
+		}
+		if err := j.Rollback(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got []Kind
+		for _, step := range j.Steps[9:] {
+			got = append(got, step.Kind)
+		}
+		wanted := []Kind{RemoveLock, RemoveLock, Move, CreateLock, CreateLock, Push}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+		if !reflect.DeepEqual(*j.Steps[10].Lock, rescoped) {
+			t.Errorf("got %v wanted %v", *j.Steps[10].Lock, rescoped)
+		}
+		if !reflect.DeepEqual(*j.Steps[13].Lock, locks[1]) {
+			t.Errorf("got %v wanted %v", *j.Steps[13].Lock, locks[1])
+		}
+	})
+}
+
+func TestPlanCreateResourceGroup(t *testing.T) {
+	config := state.DefaultConfig()
+	id := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2"
+	create := Step{Kind: CreateResourceGroup, Status: Pending, SubscriptionID: "00000000-0000-0000-0000-000000000000", ResourceGroup: "myresourcegroup2", Location: "westeurope", Tags: azure.Tags{"team": "platform"}}
+
+	t.Run("Import", func(t *testing.T) {
+		j := New("journal.json")
+		j.Plan(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+		j.PlanCreateResourceGroup("00000000-0000-0000-0000-000000000000", "myresourcegroup2", "westeurope", azure.Tags{"team": "platform"}, "azurerm_resource_group.target")
+
+		wanted := []Step{create, {Kind: Import, Status: Pending, Address: "azurerm_resource_group.target", AzureID: id}}
+		if len(j.Steps) != 7 || !reflect.DeepEqual(j.Steps[:2], wanted) {
+			t.Errorf("got %v wanted %v as first of 7 steps", j.Steps, wanted)
+		}
+	})
+
+	t.Run("Import blocks", func(t *testing.T) {
+		j := New("journal.json")
+		j.PlanImportBlocks(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "imports.tf")
+		j.PlanCreateResourceGroup("00000000-0000-0000-0000-000000000000", "myresourcegroup2", "westeurope", azure.Tags{"team": "platform"}, "azurerm_resource_group.target")
+
+		if !reflect.DeepEqual(j.Steps[0], create) {
+			t.Errorf("got %v wanted %v", j.Steps[0], create)
+		}
+		last := j.Steps[len(j.Steps)-1]
+		if last.Kind != ImportBlocks || last.IDs["azurerm_resource_group.target"] != id {
+			t.Errorf("got %v wanted an import block for %s", last, id)
+		}
+	})
+}
+
+func TestSaveLoad(t *testing.T) {
+	config := state.DefaultConfig()
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	j := New(path)
+	j.Plan(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+	if err := j.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := j.Finish(0, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := j.Finish(1, errTest("state locked")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loaded.Steps[0].Status != Done || loaded.Steps[1].Status != Failed || loaded.Steps[1].Error != "state locked" {
+		t.Errorf("got %v wanted the first step done and the second failed", loaded.Steps[:2])
+	}
+	if loaded.Complete() {
+		t.Errorf("got a complete journal wanted an incomplete one")
+	}
+}
+
+type errTest string
+
+func (e errTest) Error() string { return string(e) }
+
+func TestReconcile(t *testing.T) {
+	config := state.DefaultConfig()
+	tfstate := state.TerraformState{
+		Resources: []state.Resource{
+			{
+				Type: "azurerm_app_service",
+				Name: "app",
+				Instances: []state.Instance{
+					{Attributes: state.Attributes{ID: summary[1].FutureAzureID}},
+				},
+			},
+		},
+	}
+
+	t.Run("Removed and imported", func(t *testing.T) {
+		j := New("journal.json")
+		j.Plan(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+		j.Reconcile(tfstate)
+
+		var got []Status
+		for _, step := range j.Steps {
+			got = append(got, step.Status)
+		}
+		wanted := []Status{Pending, Done, Pending, Done, Done}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+
+	t.Run("Removed only", func(t *testing.T) {
+		j := New("journal.json")
+		j.Plan(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+		j.Reconcile(state.TerraformState{})
+
+		if j.Steps[3].Status != Done || j.Steps[4].Status != Pending {
+			t.Errorf("got %s and %s wanted %s and %s", j.Steps[3].Status, j.Steps[4].Status, Done, Pending)
+		}
+	})
+
+	t.Run("Not removed yet", func(t *testing.T) {
+		old := tfstate
+		old.Resources = []state.Resource{tfstate.Resources[0]}
+		old.Resources[0].Instances = []state.Instance{{Attributes: state.Attributes{ID: summary[1].AzureID}}}
+
+		j := New("journal.json")
+		j.Plan(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+		j.Reconcile(old)
+
+		if j.Steps[3].Status != Pending || j.Steps[4].Status != Pending {
+			t.Errorf("got %s and %s wanted %s and %s", j.Steps[3].Status, j.Steps[4].Status, Pending, Pending)
+		}
+	})
+}
+
+func TestRollback(t *testing.T) {
+	config := state.DefaultConfig()
+	t.Run("Without backup", func(t *testing.T) {
+		j := New(filepath.Join(t.TempDir(), DefaultPath))
+		j.Plan(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+		if err := j.Rollback(); err == nil {
+			t.Errorf("expected an error for a journal without backup")
+		}
+	})
+
+	t.Run("Failed after move", func(t *testing.T) {
+		j := New(filepath.Join(t.TempDir(), DefaultPath))
+		j.Plan(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+		j.Backup = "terraform.tfstate.backup"
+		for i := 0; i < 3; i++ {
+			j.Finish(i, nil)
+		}
+		j.Finish(3, errTest("state rm failed"))
+
+		if err := j.Rollback(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got []Status
+		for _, step := range j.Steps {
+			got = append(got, step.Status)
+		}
+		wanted := []Status{Done, Done, Done, Skipped, Skipped, Pending, Pending}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+
+		wantedSteps := []Step{
+			{
+				Kind:                  Move,
+				Status:                Pending,
+				SubscriptionID:        "00000000-0000-0000-0000-000000000000",
+				ResourceGroup:         "myresourcegroup2",
+				AzureIDs:              []string{summary[1].FutureAzureID},
+				TargetResourceGroupID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup",
+			},
+			{Kind: Push, Status: Pending, Path: "terraform.tfstate.backup"},
+		}
+		if !reflect.DeepEqual(j.Steps[5:], wantedSteps) {
+			t.Errorf("got %v wanted %v", j.Steps[5:], wantedSteps)
+		}
+		if !j.RolledBack {
+			t.Errorf("got %t wanted %t", j.RolledBack, true)
+		}
+	})
+
+	t.Run("Failed before move", func(t *testing.T) {
+		j := New(filepath.Join(t.TempDir(), DefaultPath))
+		j.Plan(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+		j.Backup = "terraform.tfstate.backup"
+		j.Finish(0, errTest("delete failed"))
+
+		if err := j.Rollback(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		wanted := Step{Kind: Push, Status: Pending, Path: "terraform.tfstate.backup"}
+		if len(j.Steps) != 6 || !reflect.DeepEqual(j.Steps[5], wanted) {
+			t.Errorf("got %v wanted %v as only additional step", j.Steps[5:], wanted)
+		}
+	})
+
+	t.Run("Import blocks", func(t *testing.T) {
+		j := New(filepath.Join(t.TempDir(), DefaultPath))
+		j.PlanImportBlocks(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "imports.tf")
+		j.Backup = "terraform.tfstate.backup"
+		for i := range j.Steps {
+			j.Finish(i, nil)
+		}
+
+		if err := j.Rollback(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		wanted := []Step{
+			{Kind: Push, Status: Pending, Path: "terraform.tfstate.backup"},
+			{Kind: RemoveImportBlocks, Status: Pending, Path: "imports.tf"},
+		}
+		if got := j.Steps[len(j.Steps)-2:]; !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v as last steps", got, wanted)
+		}
+	})
+}
+
+func TestPlanRewrite(t *testing.T) {
+	config := state.DefaultConfig()
+	j := New("journal.json")
+	references := []state.Reference{{Address: "azurerm_app_service.app", Attribute: "resource_group_name", Value: "myresourcegroup", FutureValue: "myresourcegroup2"}}
+	j.PlanRewrite(config, summary, references, "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+
+	wanted := Step{
+		Kind:                  Rewrite,
+		Status:                Pending,
+		IDs:                   map[string]string{summary[1].TerraformID: summary[1].FutureAzureID},
+		References:            references,
+		TargetResourceGroupID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2",
+	}
+	if len(j.Steps) != 4 || !reflect.DeepEqual(j.Steps[3], wanted) {
+		t.Fatalf("got %v wanted %v as last of 4 steps", j.Steps, wanted)
+	}
+
+	t.Run("Reconciled", func(t *testing.T) {
+		j.Reconcile(state.TerraformState{
+			Resources: []state.Resource{
+				{
+					Type:      "azurerm_app_service",
+					Name:      "app",
+					Instances: []state.Instance{{Attributes: state.Attributes{ID: summary[1].AzureID}}},
+				},
+			},
+		})
+		if j.Steps[3].Status != Pending {
+			t.Errorf("got %s wanted %s", j.Steps[3].Status, Pending)
+		}
+
+		j.Reconcile(state.TerraformState{
+			Resources: []state.Resource{
+				{
+					Type:      "azurerm_app_service",
+					Name:      "app",
+					Instances: []state.Instance{{Attributes: state.Attributes{ID: summary[1].FutureAzureID}}},
+				},
+			},
+		})
+		if j.Steps[3].Status != Done {
+			t.Errorf("got %s wanted %s", j.Steps[3].Status, Done)
+		}
+	})
+}
+
+func TestPlanImportBlocks(t *testing.T) {
+	config := state.DefaultConfig()
+	j := New("journal.json")
+	j.PlanImportBlocks(config, summary, "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "imports.tf")
+
+	wanted := []Step{
+		{Kind: Remove, Status: Pending, Address: summary[1].TerraformID},
+		{Kind: ImportBlocks, Status: Pending, Path: "imports.tf", IDs: map[string]string{summary[1].TerraformID: summary[1].FutureAzureID}},
+	}
+	if len(j.Steps) != 5 || !reflect.DeepEqual(j.Steps[3:], wanted) {
+		t.Errorf("got %v wanted %v as last of 5 steps", j.Steps, wanted)
+	}
+}
+
+func TestPlanNoImport(t *testing.T) {
+	config := state.DefaultConfig()
+	update := state.ResourceInstanceSummary{
+		AzureID:        summary[1].AzureID,
+		TerraformID:    "azapi_update_resource.app",
+		FutureAzureID:  summary[1].FutureAzureID,
+		Type:           "azapi_update_resource",
+		SubscriptionID: "00000000-0000-0000-0000-000000000000",
+		ResourceGroup:  "myresourcegroup",
+		ARMType:        "Microsoft.Web/sites",
+		APIVersion:     "2022-09-01",
+	}
+	j := New("journal.json")
+	j.Plan(config, append(summary, update), "00000000-0000-0000-0000-000000000000", "myresourcegroup2")
+
+	wanted := []Step{
+		{Kind: Remove, Status: Pending, Address: summary[1].TerraformID},
+		{Kind: Import, Status: Pending, Address: summary[1].TerraformID, AzureID: summary[1].FutureAzureID},
+		{Kind: Remove, Status: Pending, Address: update.TerraformID},
+	}
+	if len(j.Steps) != 6 || !reflect.DeepEqual(j.Steps[3:], wanted) {
+		t.Errorf("got %v wanted %v as last of 6 steps", j.Steps, wanted)
+	}
+}
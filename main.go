@@ -3,38 +3,84 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
-	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/aristosvo/aztfmove/azure"
+	"github.com/aristosvo/aztfmove/azureid"
+	"github.com/aristosvo/aztfmove/journal"
+	"github.com/aristosvo/aztfmove/plan"
 	"github.com/aristosvo/aztfmove/state"
 )
 
 var (
 	tfVars     state.ArrayVars
 	tfVarFiles state.ArrayVarFiles
+	targetTags azure.Tags
+	targets    state.Addresses
+	excludes   state.Addresses
+	tags       state.TagFilter
+	// stateConfig holds the type config and the provider sources and subscriptions set with flags, see state.Config.
+	stateConfig = state.DefaultConfig()
 
-	// TODO: should probably refactor `-resource` and `-module` to `-target` to mimic terraform flags as much as possible
 	resourceFlag            = flag.String("resource", "*", "Terraform resource to be moved. For example 'module.storage.azurerm_storage_account.example'.")
-	moduleFlag              = flag.String("module", "*", "Terraform module to be moved. For example 'module.storage'.")
+	moduleFlag              = flag.String("module", "*", "Terraform module to be moved, including its child modules and all its instances. For example 'module.storage' or 'module.storage[\"eu\"]'.")
 	sourceResourceGroupFlag = flag.String("resource-group", "*", "Azure resource group to be moved. For example 'example-source-resource-group'.")
-	sourceSubscriptionFlag  = flag.String("subscription-id", os.Getenv("ARM_SUBSCRIPTION_ID"), "subscription where resources are currently. Environment variable 'ARM_SUBSCRIPTION_ID' has the same functionality.")
+	sourceSubscriptionFlag  = flag.String("subscription-id", os.Getenv("ARM_SUBSCRIPTION_ID"), "subscription where resources are currently. Environment variable 'ARM_SUBSCRIPTION_ID' has the same functionality. Use '*' to move resources from multiple subscriptions.")
 	targetResourceGroupFlag = flag.String("target-resource-group", "", "Azure resource group name where resources are moved. For example 'example-target-resource-group'. (required)")
 	targetSubscriptionFlag  = flag.String("target-subscription-id", *sourceSubscriptionFlag, "Azure subscription ID where resources are moved. If not specified resources are moved within the subscription.")
 	autoApproveFlag         = flag.Bool("auto-approve", false, "aztfmove first shows which resources are selected for a move and requires approval. If you want to approve automatically, use this flag.")
-	dryRunFlag              = flag.Bool("dry-run", false, "if set to true, aztfmove only shows which resources are selected for a move.")
+	dryRunFlag              = flag.Bool("dry-run", false, "if set to true, aztfmove only shows which resources are selected for a move and lets Azure validate if the move will succeed.")
+	journalFlag             = flag.String("journal", journal.DefaultPath, "file in which every step of the move is recorded, used by 'aztfmove resume' and 'aztfmove rollback'.")
+	validateFlag            = flag.Bool("validate", false, "if set to true, aztfmove shows which resources are selected and only lets Azure validate the move, without showing the actions of a dry-run. It exits with an error when the validation fails.")
+	typeConfigFlag          = flag.String("type-config", "", "JSON file with azurerm resource types, adding to or replacing the types known by aztfmove. See 'state/types.json' for the format.")
+	outFlag                 = flag.String("out", "", "file to save the plan to, used by 'aztfmove plan'. The plan is applied with 'aztfmove apply'.")
+	outputFlag              = flag.String("output", "text", "output format, 'text' or 'json'. With 'json' aztfmove only prints a versioned JSON document of the planned move, like a dry-run.")
 	noColorFlag             = flag.Bool("no-color", false, "if set to true, aztfmove prints without color.")
-	// TODO: var excludeResourcesFlag = flag.String("exclude-resources", "-", "Terraform resources to be excluded from moving. For example 'module.storage.azurerm_storage_account.example,module.storage.azurerm_storage_account.example'.")
-	// but..., this is not according to previously stated principle to mimic terraform flags as much as possible
+	rewriteStateFlag        = flag.Bool("rewrite-state", false, "if set to true, aztfmove corrects the Terraform state by rewriting the IDs in the pulled state and pushing it, instead of 'terraform state rm' and 'terraform import' per resource.")
+	emitImportBlocksFlag    = flag.String("emit-import-blocks", "", "Terraform configuration file to write import blocks to, i.e. 'imports.tf'. Resources are removed from the Terraform state and imported by the next 'terraform apply' instead of by aztfmove.")
+	recreateBlockingFlag    = flag.String("recreate-blocking", string(journal.RecreateNone), "how deleted blocking resources are recreated after the move: 'none' leaves it to the next 'terraform apply', 'terraform' runs 'terraform apply -target -auto-approve' for them, which applies changes to their dependencies as well without showing a plan, 'arm' recreates them in Azure from their properties before the move and imports them.")
+	includeUnmanagedFlag    = flag.Bool("include-unmanaged", false, "if set to true, resources in the source resource groups which are not in the Terraform state are moved as well, when Azure supports moving them.")
+	includeReferencesFlag   = flag.Bool("include-references", false, "if set to true, resources referring to the selected resources by ID, like private endpoints in other modules, are selected as well when these can be moved.")
+	createTargetFlag        = flag.Bool("create-target-resource-group", false, "if set to true, the target resource group is created in the location set with -target-location when it doesn't exist.")
+	targetLocationFlag      = flag.String("target-location", "", "Azure location of the target resource group to create. For example 'westeurope'.")
+	importTargetFlag        = flag.String("import-target-resource-group", "", "Terraform address of the azurerm_resource_group to import the created target resource group to. For example 'azurerm_resource_group.target'.")
+	checkTagsFlag           = flag.Bool("check-tags", false, "if set to true, resources are selected by their tags in Azure instead of the tags in the Terraform state, differences are reported.")
+	manageLocksFlag         = flag.Bool("manage-locks", false, "if set to true, management locks on the source and target resource groups and on the selected resources are removed before the move and created again on their new scope afterwards.")
+	refreshOnlyPlanFlag     = flag.Bool("refresh-only-plan", false, "if set to true, aztfmove runs 'terraform plan -refresh-only' after the move to check the corrected Terraform state.")
 )
 
 func init() {
 	flag.Var(&tfVars, "var", "use this like you'd use Terraform \"-var\", i.e. \"-var 'test1=123' -var 'test2=312'\" ")
+	flag.Func("provider-source", "source of the azurerm or azapi provider to accept besides the ones of 'registry.terraform.io' and 'registry.opentofu.org', i.e. a private registry mirror like \"-provider-source 'terraform.example.com/hashicorp/azurerm'\". Can be repeated.", stateConfig.AddProviderSource)
+	flag.Func("provider-subscription", "subscription of a provider configuration, for resources of which the subscription can't be derived from the ID, i.e. \"-provider-subscription 'azurerm.hub=00000000-0000-0000-0000-000000000000'\". Can be repeated.", func(value string) error {
+		idx := strings.Index(value, "=")
+		if idx < 1 {
+			return fmt.Errorf("no 'provider=subscription' in arg: %s", value)
+		}
+		stateConfig.SetProviderSubscription(value[:idx], value[idx+1:])
+		return nil
+	})
+	flag.Var(&targets, "target", "Terraform address to be moved, like Terraform \"-target\": a module, resource or instance, i.e. \"-target 'module.app[\\\"eu\\\"].azurerm_storage_account.sa[0]'\". Accepts '*' and '?' as wildcards and a regular expression between slashes. Can be repeated.")
+	flag.Var(&excludes, "exclude", "Terraform address to be left out of the move, with the same syntax as \"-target\". Can be repeated.")
+	flag.Var(&tags, "tag", "tag of the resources to be moved, i.e. \"-tag 'workload=web|workload=api' -tag 'costcenter=1234'\": every \"-tag\" has to match, of the tags separated by '|' one. Values accept '*' and '?' as wildcards.")
+	flag.Var(&targetTags, "target-tag", "tag of the target resource group to create, i.e. \"-target-tag 'environment=production' -target-tag 'team=platform'\" ")
 	flag.Var(&tfVarFiles, "var-file", "use this like you'd use Terraform \"-var-file\", i.e. \"-var-file='tst.tfvars'\" ")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of aztfmove:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  aztfmove [flags]                 move the selected resources\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  aztfmove plan -out=file [flags]  save the plan to move the selected resources\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  aztfmove apply [flags] planfile  move the resources exactly as planned\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  aztfmove resume [flags]          finish a partially failed move recorded in the journal\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  aztfmove rollback [flags]        move resources back and restore the state backup recorded in the journal\n\n")
+		flag.PrintDefaults()
+	}
 }
 
 var (
@@ -72,81 +118,635 @@ func Color(colorString string) func(...interface{}) string {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "resume":
+			flag.CommandLine.Parse(os.Args[2:])
+			resume()
+			return
+		case "rollback":
+			flag.CommandLine.Parse(os.Args[2:])
+			rollback()
+			return
+		case "plan":
+			flag.CommandLine.Parse(os.Args[2:])
+			move(true)
+			return
+		case "apply":
+			flag.CommandLine.Parse(os.Args[2:])
+			apply()
+			return
+		}
+	}
+
 	flag.Parse()
+	move(false)
+}
+
+// move selects the resources to move and moves them, or only saves the plan to move them when planOnly is set.
+func move(planOnly bool) {
 	validateInput()
+	loadTypeConfig()
+
+	if planOnly && *outFlag == "" {
+		fmt.Printf("%s out is required to save the plan\n", Fata("Error:"))
+		os.Exit(1)
+	}
 
-	if *targetSubscriptionFlag == "" || *targetSubscriptionFlag == *sourceSubscriptionFlag {
-		fmt.Println(Good("No unique \"-target-subscription-id\" specified, move will be within the same subscription:"))
+	if !planOnly && !*dryRunFlag && !*validateFlag && *outputFlag == "text" {
+		checkUnfinishedJournal()
+	}
+
+	if *sourceSubscriptionFlag != "*" && *targetSubscriptionFlag == "" {
 		*targetSubscriptionFlag = *sourceSubscriptionFlag
-	} else {
-		fmt.Println(Good("Target subscription specified, move will be to a different subscription:"))
 	}
-	fmt.Printf(" %s -> %s \n", *sourceSubscriptionFlag, *targetSubscriptionFlag)
+	if *outputFlag == "text" {
+		if *sourceSubscriptionFlag == "*" {
+			fmt.Println(Good("All subscriptions selected, resources can be moved from multiple subscriptions:"))
+		} else if *targetSubscriptionFlag == *sourceSubscriptionFlag {
+			fmt.Println(Good("No unique \"-target-subscription-id\" specified, move will be within the same subscription:"))
+		} else {
+			fmt.Println(Good("Target subscription specified, move will be to a different subscription:"))
+		}
+		fmt.Printf(" %s -> %s \n", *sourceSubscriptionFlag, *targetSubscriptionFlag)
+	}
 
-	tfstate, err := state.PullRemote()
+	tf, err := state.NewTerraformExec(".", tfVars, tfVarFiles)
 	if err != nil {
-		fmt.Printf("%s Terraform state is not found. Try `terraform init`.", Fata("Error:"))
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
 		os.Exit(1)
 	}
 
-	resourceInstances, sourceResourceGroup, err := tfstate.Filter(*resourceFlag, *moduleFlag, *sourceResourceGroupFlag, *sourceSubscriptionFlag, *targetResourceGroupFlag, *targetSubscriptionFlag)
+	tfstate, err := tf.Pull(context.Background())
+	if err != nil {
+		fmt.Printf("%s Terraform state is not found. Try `terraform init`.\n %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+
+	var creds *azure.Credentials
+	selection := state.Selection{Resource: *resourceFlag, Module: *moduleFlag, Targets: targets, Excludes: excludes, Tags: tags}
+	var drift []string
+	if *checkTagsFlag && len(tags) > 0 {
+		creds = authenticate()
+		selection.TagSource = azureTags(creds, &drift)
+	}
+	resourceInstances, err := tfstate.Filter(stateConfig, selection, *sourceResourceGroupFlag, *sourceSubscriptionFlag, *targetResourceGroupFlag, *targetSubscriptionFlag)
+	if err != nil {
+		fmt.Printf("%s %v", Fata("Error:"), err)
+		os.Exit(1)
+	}
+	unmatched, err := tfstate.Unmatched(stateConfig, selection)
 	if err != nil {
 		fmt.Printf("%s %v", Fata("Error:"), err)
 		os.Exit(1)
 	}
+	if *includeReferencesFlag {
+		resourceInstances, err = tfstate.IncludeReferences(stateConfig, resourceInstances, *sourceResourceGroupFlag, *sourceSubscriptionFlag, *targetResourceGroupFlag, *targetSubscriptionFlag)
+		if err != nil {
+			fmt.Printf("%s %v", Fata("Error:"), err)
+			os.Exit(1)
+		}
+	}
+
+	references := tfstate.References(stateConfig, resourceInstances)
+
+	if *outputFlag == "json" {
+		printJSON(resourceInstances, references)
+		os.Exit(0)
+	}
+
+	printUnmatched(unmatched)
+	printIgnoredProviderSources(tfstate.IgnoredProviderSources(stateConfig))
+	printTagDrift(drift)
+	printBlockingMovement(resourceInstances.BlockingMovement(stateConfig))
+	printNotSupported(resourceInstances.NotSupported(stateConfig))
+	printNotNeeded(resourceInstances.NoMovementNeeded(stateConfig))
+	printUnclassified(resourceInstances.Unclassified(stateConfig))
+	printToMoveInAzure(resourceInstances.MoveGroups(stateConfig))
+	printToCorrectInTF(resourceInstances.ToCorrectInTFState(stateConfig))
+	printReferences(references)
+	printDanglingReferences(references)
+
+	if creds == nil {
+		creds = authenticate()
+	}
+	createTarget := checkTargetResourceGroup(creds)
+	unmanaged := inventory(creds, tfstate, resourceInstances)
+	printUnmanaged(unmanaged)
+	if *includeUnmanagedFlag {
+		resourceInstances = append(resourceInstances, unmanaged.Movable(stateConfig)...)
+		references = tfstate.References(stateConfig, resourceInstances)
+	}
+	locks := listLocks(creds, resourceInstances, createTarget)
+	printLocks(locks)
+	// a dry-run reports a move which fails validation after showing what it would do
+	valid := validate(creds, resourceInstances, locks, createTarget)
+	if !valid && !*dryRunFlag {
+		os.Exit(1)
+	}
 
-	printBlockingMovement(resourceInstances.BlockingMovement())
-	printNotSupported(resourceInstances.NotSupported())
-	printNotNeeded(resourceInstances.NoMovementNeeded())
-	printToMoveInAzure(resourceInstances.MovableOnAzure())
-	printToCorrectInTF(resourceInstances.ToCorrectInTFState())
+	if planOnly {
+		savePlan(tfstate, resourceInstances)
+		os.Exit(0)
+	}
+
+	if *validateFlag {
+		fmt.Print(Good("\nValidation complete!\n"))
+		fmt.Println("Resources are not moved to the specified resource group, Azure confirmed the selected resources can be moved.")
+		os.Exit(0)
+	}
 
 	if !*dryRunFlag && !*autoApproveFlag {
 		askConfirmation()
 	}
 
-	if tfIDsToRemove, azureIDsToDelete := resourceInstances.BlockingMovement(); len(azureIDsToDelete) > 0 {
-		fmt.Print(Azure("\nBlocking resources will be deleted in Azure."))
-		if *dryRunFlag {
-			fmt.Print(" (dry-run!)")
+	if *dryRunFlag {
+		printDryRun(resourceInstances, references, locks, createTarget)
+		if !valid {
+			fmt.Printf("\n%s Dry-run complete, but Azure reported the move fails, see the validation errors above.\n", Fata("Error:"))
+			os.Exit(1)
 		}
-		deleteAzureResources(azureIDsToDelete, sourceResourceGroup, *sourceSubscriptionFlag)
-		fmt.Print(Good("\n\nBlocking resources are deleted in Azure."))
-		if *dryRunFlag {
-			fmt.Print(" (dry-run!)")
+		fmt.Print(Good("\nDry-run complete!\n"))
+		fmt.Printf("Resources are not moved to the specified resource group, but the resources actions (and corresponding %s and %s commands) are visible above.\n", Azure("az cli"), Terraform("terraform"))
+		os.Exit(0)
+	}
+
+	run(creds, tf, resourceInstances, references, locks, createTarget)
+}
+
+// run moves the selected resources, every step is recorded in the journal.
+func run(creds *azure.Credentials, tf state.Terraform, resourceInstances state.ResourcesInstanceSummary, references []state.Reference, locks []azure.Lock, createTarget bool) {
+	j := journal.New(*journalFlag)
+	if *rewriteStateFlag {
+		j.PlanRewrite(stateConfig, resourceInstances, references, *targetSubscriptionFlag, *targetResourceGroupFlag)
+	} else if *emitImportBlocksFlag != "" {
+		j.PlanImportBlocks(stateConfig, resourceInstances, *targetSubscriptionFlag, *targetResourceGroupFlag, *emitImportBlocksFlag)
+	} else {
+		j.Plan(stateConfig, resourceInstances, *targetSubscriptionFlag, *targetResourceGroupFlag)
+	}
+	j.PlanRecreate(stateConfig, resourceInstances, journal.Recreation(*recreateBlockingFlag))
+	if *manageLocksFlag {
+		j.PlanLocks(stateConfig, locks, resourceInstances)
+	}
+	if createTarget {
+		j.PlanCreateResourceGroup(*targetSubscriptionFlag, *targetResourceGroupFlag, *targetLocationFlag, targetTags, *importTargetFlag)
+	}
+	backup, err := state.Backup(context.Background(), tf, ".", time.Now())
+	if err != nil {
+		fmt.Printf("\n%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+	j.Backup = backup
+	fmt.Printf("\nTerraform state is backed up to %s, use %s to undo the move.\n", j.Backup, Good("aztfmove rollback"))
+	if err := j.Save(); err != nil {
+		fmt.Printf("\n%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+	execute(creds, tf, j)
+	printCompleted(j)
+
+	if *refreshOnlyPlanFlag {
+		checkRefreshOnlyPlan(tf)
+	}
+}
+
+func loadTypeConfig() {
+	if *typeConfigFlag == "" {
+		return
+	}
+	if err := stateConfig.LoadTypeConfig(*typeConfigFlag); err != nil {
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+}
+
+// checkUnfinishedJournal stops when an unfinished move is recorded in the journal, a new move would overwrite it.
+func checkUnfinishedJournal() {
+	if previous, err := journal.Load(*journalFlag); err == nil && !previous.Complete() {
+		fmt.Printf("%s an unfinished move is recorded in %s. Finish it with %s or remove the journal.\n", Fata("Error:"), *journalFlag, Good("aztfmove resume"))
+		os.Exit(1)
+	}
+}
+
+// correction returns the action which corrects the Terraform state, depending on the flags.
+func correction() plan.Action {
+	if *rewriteStateFlag {
+		return plan.Rewrite
+	} else if *emitImportBlocksFlag != "" {
+		return plan.ImportBlock
+	}
+	return plan.Reimport
+}
+
+// recreation returns the action which recreates deleted blocking resources, depending on the flags.
+func recreation() plan.Action {
+	switch journal.Recreation(*recreateBlockingFlag) {
+	case journal.RecreateTerraform:
+		return plan.Apply
+	case journal.RecreateARM:
+		return plan.Recreate
+	}
+	return ""
+}
+
+// savePlan writes the selected resources with everything needed to apply them later to the file set with -out.
+func savePlan(tfstate state.TerraformState, resourceInstances state.ResourcesInstanceSummary) {
+	saved := plan.Saved{
+		Source:           plan.Scope{SubscriptionID: *sourceSubscriptionFlag, ResourceGroup: *sourceResourceGroupFlag},
+		Target:           plan.Scope{SubscriptionID: *targetSubscriptionFlag, ResourceGroup: *targetResourceGroupFlag},
+		Correct:          correction(),
+		ImportBlocksPath: *emitImportBlocksFlag,
+		RecreateBlocking: *recreateBlockingFlag,
+		ManageLocks:      *manageLocksFlag,
+		CreateTarget:     createTargetPlan(),
+		Serial:           tfstate.Serial,
+		Lineage:          tfstate.Lineage,
+		Vars:             tfVars,
+		VarFiles:         tfVarFiles,
+		Resources:        resourceInstances,
+		Categories:       plan.Categories(stateConfig, resourceInstances),
+	}
+	if err := saved.Write(*outFlag); err != nil {
+		fmt.Printf("\n%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+
+	fmt.Print(Good("\nPlan saved!\n"))
+	fmt.Printf("Resources are not moved yet, run %s to move them exactly as planned.\n", Good("aztfmove apply "+*outFlag))
+}
+
+// apply moves the resources as saved by `aztfmove plan`, as long as the Terraform state didn't change in the meantime.
+func apply() {
+	if flag.NArg() != 1 {
+		fmt.Printf("%s the plan file is required: aztfmove apply [flags] planfile\n", Fata("Error:"))
+		os.Exit(1)
+	}
+	if len(tfVars) > 0 || len(tfVarFiles) > 0 {
+		fmt.Printf("%s variables can't be set when applying a plan, the variables of the plan are used\n", Fata("Error:"))
+		os.Exit(1)
+	}
+
+	saved, err := plan.Read(flag.Arg(0))
+	if err != nil {
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+	checkUnfinishedJournal()
+	loadTypeConfig()
+
+	// the plan is applied with the options it was made with
+	*sourceSubscriptionFlag, *sourceResourceGroupFlag = saved.Source.SubscriptionID, saved.Source.ResourceGroup
+	*targetSubscriptionFlag, *targetResourceGroupFlag = saved.Target.SubscriptionID, saved.Target.ResourceGroup
+	*rewriteStateFlag = saved.Correct == plan.Rewrite
+	*emitImportBlocksFlag = saved.ImportBlocksPath
+	*recreateBlockingFlag = saved.RecreateBlocking
+	*manageLocksFlag = saved.ManageLocks
+	if saved.CreateTarget != nil {
+		*createTargetFlag = true
+		*targetLocationFlag, targetTags, *importTargetFlag = saved.CreateTarget.Location, saved.CreateTarget.Tags, saved.CreateTarget.Address
+	}
+	tfVars, tfVarFiles = saved.Vars, saved.VarFiles
+
+	tf, err := state.NewTerraformExec(".", tfVars, tfVarFiles)
+	if err != nil {
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+	tfstate, err := tf.Pull(context.Background())
+	if err != nil {
+		fmt.Printf("%s Terraform state is not found. Try `terraform init`.\n %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+	if err := saved.Check(tfstate); err != nil {
+		fmt.Printf("%s %v. Create a new plan with %s.\n", Fata("Error:"), err, Good("aztfmove plan"))
+		os.Exit(1)
+	}
+	if err := saved.CheckCategories(stateConfig); err != nil {
+		fmt.Printf("%s %v. Apply the plan with the type config it was made with, or create a new plan with %s.\n", Fata("Error:"), err, Good("aztfmove plan"))
+		os.Exit(1)
+	}
+
+	resourceInstances := saved.Resources
+	references := tfstate.References(stateConfig, resourceInstances)
+	fmt.Printf(" %s -> %s \n", *sourceSubscriptionFlag, *targetSubscriptionFlag)
+	printBlockingMovement(resourceInstances.BlockingMovement(stateConfig))
+	printNotSupported(resourceInstances.NotSupported(stateConfig))
+	printNotNeeded(resourceInstances.NoMovementNeeded(stateConfig))
+	printUnclassified(resourceInstances.Unclassified(stateConfig))
+	printToMoveInAzure(resourceInstances.MoveGroups(stateConfig))
+	printToCorrectInTF(resourceInstances.ToCorrectInTFState(stateConfig))
+	printReferences(references)
+	printDanglingReferences(references)
+
+	creds := authenticate()
+	createTarget := checkTargetResourceGroup(creds)
+	// locks can be changed after the plan is saved, these are listed again
+	locks := listLocks(creds, resourceInstances, createTarget)
+	printLocks(locks)
+	if !validate(creds, resourceInstances, locks, createTarget) {
+		os.Exit(1)
+	}
+
+	// like `terraform apply` of a saved plan, the plan itself is the approval
+	run(creds, tf, resourceInstances, references, locks, createTarget)
+}
+
+// printJSON prints the planned move as a JSON document, see package plan.
+func printJSON(resourceInstances state.ResourcesInstanceSummary, references []state.Reference) {
+	correct := correction()
+	source := plan.Scope{SubscriptionID: *sourceSubscriptionFlag, ResourceGroup: *sourceResourceGroupFlag}
+	target := plan.Scope{SubscriptionID: *targetSubscriptionFlag, ResourceGroup: *targetResourceGroupFlag}
+
+	data, err := json.MarshalIndent(plan.New(stateConfig, resourceInstances, references, source, target, correct, recreation()), "", "  ")
+	if err != nil {
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
+
+func printCompleted(j *journal.Journal) {
+	for _, step := range j.Steps {
+		if step.Kind == journal.ImportBlocks {
+			fmt.Print(Good("\n\nCongratulations! Resources are moved in Azure and removed from the Terraform state.\n"))
+			fmt.Printf("Run %s and %s to import them again with the import blocks in %s.\n", TerraformCLI("terraform plan"), TerraformCLI("terraform apply"), step.Path)
+			printRecreated(j)
+			return
 		}
-		fmt.Print(Terraform("\n\nResources in Terraform state will be removed:"))
-		if *dryRunFlag {
-			fmt.Print(" (dry-run!)")
+	}
+	fmt.Print(Good("\n\nCongratulations! Resources are moved in Azure and corrected in Terraform.\n"))
+	printRecreated(j)
+}
+
+// printRecreated reports which deleted blocking resources are recreated, and which are left to `terraform apply`.
+func printRecreated(j *journal.Journal) {
+	recreated := map[string]bool{}
+	for _, step := range j.Steps {
+		switch {
+		case step.Kind == journal.Apply && step.Status == journal.Done:
+			for _, address := range step.Addresses {
+				recreated[address] = true
+			}
+		case step.Kind == journal.Recreate && step.Status == journal.Done:
+			recreated[step.Address] = true
 		}
-		removeTerraformResources(tfIDsToRemove)
 	}
 
-	if azureIDs := resourceInstances.MovableOnAzure(); len(azureIDs) > 0 {
-		fmt.Print(Azure("\nResources are on the move to the specified resource group."))
-		if *dryRunFlag {
-			fmt.Print(" (dry-run!)")
+	var restored, deleted []string
+	for _, step := range j.Steps {
+		if step.Kind != journal.Delete || step.Status != journal.Done {
+			continue
+		}
+		if recreated[step.Address] {
+			restored = append(restored, step.Address)
+		} else {
+			deleted = append(deleted, step.Address)
+		}
+	}
+	if len(restored) > 0 {
+		fmt.Println("\nBlocking resources are recreated:")
+		for _, address := range restored {
+			fmt.Println(" -", address)
 		}
-		moveAzureResources(azureIDs, sourceResourceGroup, *sourceSubscriptionFlag, *targetSubscriptionFlag, *targetResourceGroupFlag)
-		fmt.Print(Good("\n\nResources are moved to the specified resource group."))
-		if *dryRunFlag {
-			fmt.Print(" (dry-run!)")
+	}
+	if len(deleted) > 0 {
+		fmt.Printf("\n%s blocking resources are deleted and not recreated, run %s to recreate them:\n", Warn("Warning:"), TerraformCLI("terraform apply"))
+		for _, address := range deleted {
+			fmt.Println(" -", address)
 		}
 	}
+}
 
-	fmt.Print(Terraform("\n\nResources in Terraform state are enhanced:"))
-	if *dryRunFlag {
-		fmt.Print(" (dry-run!)")
+// checkRefreshOnlyPlan runs `terraform plan -refresh-only` to check the corrected state matches the resources in Azure.
+func checkRefreshOnlyPlan(tf state.Terraform) {
+	fmt.Print(Terraform("\nTerraform state is checked with a refresh-only plan."))
+	changes, err := tf.PlanRefreshOnly(context.Background())
+	if err != nil {
+		fmt.Printf("\n%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
 	}
-	reimportTerraformResources(resourceInstances.ToCorrectInTFState())
+	if changes {
+		fmt.Printf("\n%s the Terraform state differs from the resources in Azure, inspect the differences with %s.\n", Warn("Warning:"), TerraformCLI("terraform plan -refresh-only"))
+		return
+	}
+	fmt.Print(Good("\nTerraform state matches the resources in Azure.\n"))
+}
 
-	if *dryRunFlag {
-		fmt.Print(Good("\nDry-run complete!\n"))
-		fmt.Printf("Resources are not moved to the specified resource group, but the resources actions (and corresponding %s and %s commands) are visible above.\n", Azure("az cli"), Terraform("terraform"))
+// resume finishes a move recorded in the journal, retrying the steps which failed or didn't run yet.
+func resume() {
+	j, err := journal.Load(*journalFlag)
+	if err != nil {
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+	if j.Complete() {
+		fmt.Print(Good("Nothing to resume, all steps in the journal are done.\n"))
 		os.Exit(0)
 	}
+	if j.RolledBack {
+		fmt.Printf("%s the move in %s is being rolled back, finish it with %s.\n", Fata("Error:"), *journalFlag, Good("aztfmove rollback"))
+		os.Exit(1)
+	}
 
-	fmt.Print(Good("\n\nCongratulations! Resources are moved in Azure and corrected in Terraform.\n"))
+	tf, err := state.NewTerraformExec(".", tfVars, tfVarFiles)
+	if err != nil {
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+
+	tfstate, err := tf.Pull(context.Background())
+	if err != nil {
+		fmt.Printf("%s Terraform state is not found. Try `terraform init`.\n %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+	j.Reconcile(tfstate)
+	if err := j.Save(); err != nil {
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+
+	fmt.Print(Warn("Steps remaining from the journal:\n"))
+	var azureSteps bool
+	for _, step := range j.Steps {
+		if step.Status == journal.Done {
+			continue
+		}
+		if step.Kind == journal.Delete || step.Kind == journal.Move || step.Kind == journal.Recreate || step.Kind == journal.RemoveLock || step.Kind == journal.CreateLock || step.Kind == journal.CreateResourceGroup {
+			azureSteps = true
+		}
+		fmt.Printf(" - %s (%s)\n", step, step.Status)
+	}
+
+	if !*autoApproveFlag {
+		askConfirmation()
+	}
+
+	var creds *azure.Credentials
+	if azureSteps {
+		creds = authenticate()
+	}
+	execute(creds, tf, j)
+	printCompleted(j)
+
+	if *refreshOnlyPlanFlag {
+		checkRefreshOnlyPlan(tf)
+	}
+}
+
+// rollback undoes the move recorded in the journal: resources are moved back in Azure and the state backup is pushed.
+func rollback() {
+	j, err := journal.Load(*journalFlag)
+	if err != nil {
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+	if j.RolledBack && j.Complete() {
+		fmt.Print(Good("Nothing to roll back, the move is rolled back already.\n"))
+		os.Exit(0)
+	}
+
+	var deleted, created []string
+	if !j.RolledBack {
+		for _, step := range j.Steps {
+			if step.Kind == journal.Delete && step.Status == journal.Done {
+				deleted = append(deleted, step.AzureID)
+			}
+			if step.Kind == journal.CreateResourceGroup && step.Status == journal.Done {
+				created = append(created, azureid.ResourceGroupID(step.SubscriptionID, step.ResourceGroup))
+			}
+		}
+		if err := j.Rollback(); err != nil {
+			fmt.Printf("%s %v\n", Fata("Error:"), err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Print(Warn("Steps to roll back the move:\n"))
+	var azureSteps bool
+	for _, step := range j.Steps {
+		if step.Status == journal.Done || step.Status == journal.Skipped {
+			continue
+		}
+		if step.Kind == journal.Move || step.Kind == journal.RemoveLock || step.Kind == journal.CreateLock {
+			azureSteps = true
+		}
+		fmt.Printf(" - %s\n", step)
+	}
+	if len(deleted) > 0 {
+		fmt.Printf("\n%s blocking resources deleted during the move are not restored in Azure, run `terraform apply` after the rollback to recreate them:\n", Warn("Warning:"))
+		for _, id := range deleted {
+			fmt.Println(" -", id)
+		}
+	}
+	if len(created) > 0 {
+		fmt.Printf("\n%s the target resource group created during the move is not deleted, remove it when it's empty:\n", Warn("Warning:"))
+		for _, id := range created {
+			fmt.Println(" -", id)
+		}
+	}
+
+	if !*autoApproveFlag {
+		askConfirmation()
+	}
+	if err := j.Save(); err != nil {
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+
+	tf, err := state.NewTerraformExec(".", tfVars, tfVarFiles)
+	if err != nil {
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
+		os.Exit(1)
+	}
+	var creds *azure.Credentials
+	if azureSteps {
+		creds = authenticate()
+	}
+	execute(creds, tf, j)
+
+	fmt.Print(Good("\n\nRollback complete! Resources are moved back in Azure and the Terraform state is restored.\n"))
+}
+
+func printDryRun(resourceInstances state.ResourcesInstanceSummary, references []state.Reference, locks []azure.Lock, createTarget bool) {
+	if createTarget {
+		fmt.Print(Azure("\nThe target resource group is created: (dry-run!)"))
+		fmt.Println("\nThe Azure actions taken when \"-dry-run=false\" are similar to the scripted actions below:")
+		fmt.Printf(AzureCLI("  az group create --subscription %s --name %s --location %s%s\n"), *targetSubscriptionFlag, *targetResourceGroupFlag, *targetLocationFlag, tagsArgument(targetTags))
+		if *importTargetFlag != "" {
+			fmt.Printf(TerraformCLI("  terraform import '%s' '%s'\n"), *importTargetFlag, azureid.ResourceGroupID(*targetSubscriptionFlag, *targetResourceGroupFlag))
+		}
+	}
+
+	if *manageLocksFlag && len(locks) > 0 {
+		fmt.Print(Azure("\nManagement locks are removed: (dry-run!)\n"))
+		for _, lock := range locks {
+			fmt.Println(" -", lock)
+		}
+	}
+
+	if tfIDsToRemove, azureIDsToDelete := resourceInstances.BlockingMovement(stateConfig); len(azureIDsToDelete) > 0 {
+		fmt.Print(Azure("\nBlocking resources will be deleted in Azure. (dry-run!)"))
+		printDeleteAzureResources(azureIDsToDelete)
+		fmt.Print(Good("\n\nBlocking resources are deleted in Azure. (dry-run!)"))
+		fmt.Print(Terraform("\n\nResources in Terraform state will be removed: (dry-run!)"))
+		printRemoveTerraformResources(tfIDsToRemove)
+	}
+
+	if moveGroups := resourceInstances.MoveGroups(stateConfig); len(moveGroups) > 0 {
+		fmt.Print(Azure("\nResources are on the move to the specified resource group. (dry-run!)"))
+		printMoveAzureResources(moveGroups, *targetSubscriptionFlag, *targetResourceGroupFlag)
+		fmt.Print(Good("\n\nResources are moved to the specified resource group. (dry-run!)"))
+	}
+
+	if *manageLocksFlag && len(locks) > 0 {
+		fmt.Print(Azure("\n\nManagement locks are created again: (dry-run!)\n"))
+		for _, lock := range journal.RescopeLocks(stateConfig, locks, resourceInstances) {
+			fmt.Println(" -", lock)
+		}
+	}
+
+	switch {
+	case *emitImportBlocksFlag != "":
+		tfIDs := make([]string, 0, len(resourceInstances.ToCorrectInTFState(stateConfig)))
+		for tfID := range resourceInstances.ToCorrectInTFState(stateConfig) {
+			tfIDs = append(tfIDs, tfID)
+		}
+		sort.Strings(tfIDs)
+		fmt.Print(Terraform("\n\nResources in Terraform state will be removed: (dry-run!)"))
+		printRemoveTerraformResources(tfIDs)
+		fmt.Printf(Terraform("\n\nImport blocks are written to %s: (dry-run!)\n"), *emitImportBlocksFlag)
+		fmt.Print(string(state.ImportBlocks(resourceInstances.ToImport(stateConfig))))
+	case *rewriteStateFlag:
+		fmt.Print(Terraform("\n\nResources in Terraform state are rewritten: (dry-run!)"))
+		printRewriteTerraformResources(resourceInstances.ToCorrectInTFState(stateConfig), references)
+	default:
+		fmt.Print(Terraform("\n\nResources in Terraform state are enhanced: (dry-run!)"))
+		printReimportTerraformResources(resourceInstances.ToCorrectInTFState(stateConfig), resourceInstances.ToImport(stateConfig))
+	}
+
+	if tfIDs, _ := resourceInstances.BlockingMovement(stateConfig); len(tfIDs) > 0 {
+		printRecreateBlockingResources(tfIDs)
+	}
+}
+
+func printRecreateBlockingResources(tfIDs []string) {
+	switch journal.Recreation(*recreateBlockingFlag) {
+	case journal.RecreateTerraform:
+		fmt.Print(Terraform("\n\nBlocking resources are recreated by Terraform: (dry-run!)"))
+		fmt.Println("\nThe Terraform actions taken when \"-dry-run=false\" are similar to the scripted actions below:")
+		fmt.Printf(TerraformCLI("  terraform apply -auto-approve %s %s -target='%s'\n"), strings.Join(tfVarFiles, " "), strings.Join(tfVars, " "), strings.Join(tfIDs, "' -target='"))
+		fmt.Printf("%s pending changes to the dependencies of these resources are applied as well, check them with %s first.\n", Warn("Warning:"), TerraformCLI("terraform plan -target"))
+	case journal.RecreateARM:
+		fmt.Print(Azure("\n\nBlocking resources are recreated in Azure and imported in Terraform: (dry-run!)\n"))
+		for _, tfID := range tfIDs {
+			fmt.Println(" -", tfID)
+		}
+	default:
+		fmt.Printf("\n\n%s blocking resources are not recreated, run %s after the move to recreate them:\n", Warn("Warning:"), TerraformCLI("terraform apply"))
+		for _, tfID := range tfIDs {
+			fmt.Println(" -", tfID)
+		}
+	}
 }
 
 func validateInput() {
@@ -159,6 +759,112 @@ func validateInput() {
 		fmt.Printf("%s No resource subscription known, specify environment variable ARM_SUBSCRIPTION_ID or flag -subscription-id\n", Fata("Error:"))
 		os.Exit(1)
 	}
+
+	if *outputFlag != "text" && *outputFlag != "json" {
+		fmt.Printf("%s output must be 'text' or 'json'\n", Fata("Error:"))
+		os.Exit(1)
+	}
+
+	if *outputFlag == "json" && *validateFlag {
+		fmt.Printf("%s output 'json' only describes the move, it can't be combined with validate\n", Fata("Error:"))
+		os.Exit(1)
+	}
+
+	if *outputFlag == "json" && *checkTagsFlag {
+		fmt.Printf("%s output 'json' only describes the move, it can't be combined with check-tags\n", Fata("Error:"))
+		os.Exit(1)
+	}
+
+	if *outputFlag == "json" && *includeUnmanagedFlag {
+		fmt.Printf("%s output 'json' only describes the move in Terraform, it can't be combined with include-unmanaged\n", Fata("Error:"))
+		os.Exit(1)
+	}
+
+	if *rewriteStateFlag && *emitImportBlocksFlag != "" {
+		fmt.Printf("%s rewrite-state and emit-import-blocks can't be combined\n", Fata("Error:"))
+		os.Exit(1)
+	}
+
+	switch journal.Recreation(*recreateBlockingFlag) {
+	case journal.RecreateNone, journal.RecreateTerraform, journal.RecreateARM:
+	default:
+		fmt.Printf("%s recreate-blocking must be 'terraform', 'arm' or 'none'\n", Fata("Error:"))
+		os.Exit(1)
+	}
+
+	if *checkTagsFlag && len(tags) == 0 {
+		fmt.Printf("%s check-tags requires resources to be selected with tag\n", Fata("Error:"))
+		os.Exit(1)
+	}
+
+	if *createTargetFlag && *targetLocationFlag == "" {
+		fmt.Printf("%s target-location is required to create the target resource group\n", Fata("Error:"))
+		os.Exit(1)
+	}
+
+	if !*createTargetFlag && (*targetLocationFlag != "" || len(targetTags) > 0 || *importTargetFlag != "") {
+		fmt.Printf("%s target-location, target-tag and import-target-resource-group are only used with create-target-resource-group\n", Fata("Error:"))
+		os.Exit(1)
+	}
+
+	if *sourceSubscriptionFlag == "*" && (*targetSubscriptionFlag == "" || *targetSubscriptionFlag == "*") {
+		fmt.Printf("%s target-subscription-id is required when resources are moved from multiple subscriptions\n", Fata("Error:"))
+		os.Exit(1)
+	}
+}
+
+// azureTags returns the tag source which reads the tags of the instances from Azure. The addresses of instances of
+// which the tags in Azure select differently than the tags in the Terraform state are added to drift. Instances
+// which are no Azure resource, like storage containers, or can't have tags, like subnets, keep the tags in the
+// Terraform state.
+func azureTags(creds *azure.Credentials, drift *[]string) func(r state.Resource, instance state.Instance) (map[string]string, error) {
+	return func(r state.Resource, instance state.Instance) (map[string]string, error) {
+		subscriptionID, _, ok := azureid.Scope(instance.Attributes.ID)
+		if !ok || !instance.Attributes.Taggable() {
+			return instance.Attributes.Tags(), nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*60*time.Second)
+		defer cancel()
+		found, err := azure.GetTags(ctx, creds.TagsClient(subscriptionID), instance.Attributes.ID)
+		if err != nil {
+			return nil, fmt.Errorf("tags of %s cannot be read: %v", instance.ID(r), err)
+		}
+		if tags.Matches(found) != tags.Matches(instance.Attributes.Tags()) {
+			*drift = append(*drift, instance.ID(r))
+		}
+		return found, nil
+	}
+}
+
+func printTagDrift(addresses []string) {
+	if len(addresses) == 0 {
+		return
+	}
+	fmt.Print(Warn("\nResources of which the tags in Azure differ from the Terraform state, these are selected by the tags in Azure:\n"))
+	for _, address := range addresses {
+		fmt.Println(" -", address)
+	}
+}
+
+func printIgnoredProviderSources(sources []string) {
+	if len(sources) == 0 {
+		return
+	}
+	fmt.Print(Warn("\nResources of these azurerm and azapi provider sources are ignored, accept them with \"-provider-source\":\n"))
+	for _, source := range sources {
+		fmt.Println(" -", source)
+	}
+}
+
+func printUnmatched(addresses []string) {
+	if len(addresses) == 0 {
+		return
+	}
+	fmt.Print(Warn("\nAddresses which match no resource instance in the Terraform state:\n"))
+	for _, address := range addresses {
+		fmt.Println(" -", address)
+	}
 }
 
 func printBlockingMovement(terraformIDs []string, azureIDs []string) {
@@ -191,6 +897,16 @@ func printNotNeeded(terraformIDs []string) {
 	}
 }
 
+func printUnclassified(terraformIDs []string) {
+	if len(terraformIDs) == 0 {
+		return
+	}
+	fmt.Print(Warn("\nResources with unknown move support, considered movable:\n (add these to a -type-config when Azure can't move them)\n"))
+	for _, id := range terraformIDs {
+		fmt.Println(" -", id)
+	}
+}
+
 func printToCorrectInTF(resources map[string]string) {
 	fmt.Print(Terraform("\nResources to be corrected in Terraform:\n"))
 	for k, v := range resources {
@@ -198,153 +914,368 @@ func printToCorrectInTF(resources map[string]string) {
 	}
 }
 
-func printToMoveInAzure(azureIDs []string) {
+func printReferences(references []state.Reference) {
+	if len(references) == 0 {
+		return
+	}
+	fmt.Print(Terraform("\nAttributes referring to the moved resources:\n"))
+	if !*rewriteStateFlag {
+		fmt.Println(" (corrected by the import or the next refresh, \"-rewrite-state\" rewrites them right away)")
+	}
+	for _, ref := range references {
+		fmt.Printf(" - %s: %s -> %s\n", ref, ref.Value, ref.FutureValue)
+	}
+}
+
+func printDanglingReferences(references []state.Reference) {
+	var dangling []state.Reference
+	for _, ref := range references {
+		if ref.Dangling {
+			dangling = append(dangling, ref)
+		}
+	}
+	if len(dangling) == 0 {
+		return
+	}
+	fmt.Print(Warn("\nDangling references from resources which are not selected:\n (these can show up as changes or replacements in the next plan, select them as well with \"-include-references\")\n"))
+	for _, ref := range dangling {
+		fmt.Printf(" - %s: %s\n", ref, ref.Value)
+	}
+}
+
+func printToMoveInAzure(moveGroups []state.MoveGroup) {
 	fmt.Print(Azure("\nResources to be moved in Azure:\n"))
-	for _, id := range azureIDs {
-		fmt.Println(" -", id)
+	for _, group := range moveGroups {
+		if len(moveGroups) > 1 {
+			fmt.Printf(" from resource group %s (subscription %s):\n", group.ResourceGroup, group.SubscriptionID)
+		}
+		for _, id := range group.AzureIDs {
+			fmt.Println(" -", id)
+		}
 	}
 }
 
-func askConfirmation() {
-	fmt.Print(Good("\nCan you confirm these resources should be moved?"))
-	if *dryRunFlag {
-		fmt.Print(" (dry-run!)")
+// inventory lists the resources in the source resource groups of the selection which are not in the Terraform state.
+func inventory(creds *azure.Credentials, tfstate state.TerraformState, resourceInstances state.ResourcesInstanceSummary) state.ResourcesInstanceSummary {
+	var present []string
+	for _, group := range resourceInstances.MoveGroups(stateConfig) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*60*time.Second)
+		ids, err := azure.ListResourceGroup(ctx, creds.ResourcesClient(group.SubscriptionID), group.ResourceGroup)
+		cancel()
+		if err != nil {
+			fmt.Printf("%s resources in resource group %s cannot be listed: %v\n", Fata("Error:"), group.ResourceGroup, err)
+			os.Exit(1)
+		}
+		present = append(present, ids...)
 	}
-	fmt.Printf("\nCheck the Azure documentation on moving Azure resources (https://docs.microsoft.com/en-us/azure/azure-resource-manager/management/move-resource-group-and-subscription) for all the details for your specific resources.")
-	fmt.Print(Good("\n\nType 'yes' to confirm: "))
+	return tfstate.Unmanaged(present, *targetResourceGroupFlag, *targetSubscriptionFlag)
+}
 
-	reader := bufio.NewReader(os.Stdin)
-	inputString, err := reader.ReadString('\n')
+// checkTargetResourceGroup stops when the target resource group doesn't exist, unless it's created. It reports whether
+// the target resource group is created.
+func checkTargetResourceGroup(creds *azure.Credentials) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*60*time.Second)
+	defer cancel()
+	exists, err := azure.ResourceGroupExists(ctx, creds.GroupsClient(*targetSubscriptionFlag), *targetResourceGroupFlag)
 	if err != nil {
-		fmt.Printf("%s confirmation of the import errored out\n", Fata("Error:"))
+		fmt.Printf("%s target resource group %s cannot be checked: %v\n", Fata("Error:"), *targetResourceGroupFlag, err)
+		os.Exit(1)
+	}
+
+	switch {
+	case exists:
+		return false
+	case !*createTargetFlag:
+		fmt.Printf("%s target resource group %s doesn't exist in subscription %s, create it or use \"-create-target-resource-group -target-location=<location>\"\n", Fata("Error:"), *targetResourceGroupFlag, *targetSubscriptionFlag)
+		os.Exit(1)
+	case *validateFlag:
+		fmt.Printf("%s target resource group %s doesn't exist yet, Azure can't validate the move to it\n", Fata("Error:"), *targetResourceGroupFlag)
 		os.Exit(1)
 	}
-	inputString = strings.TrimSuffix(inputString, "\n")
-	if inputString != "yes" {
-		fmt.Printf("\nMove is canceled\n")
-		os.Exit(0)
 
+	fmt.Print(Warn("\nTarget resource group doesn't exist and is created before the move:\n"))
+	fmt.Printf(" - %s in %s\n", azureid.ResourceGroupID(*targetSubscriptionFlag, *targetResourceGroupFlag), *targetLocationFlag)
+	if *importTargetFlag != "" {
+		fmt.Printf(" - imported as %s\n", *importTargetFlag)
 	}
+	return true
 }
 
-func deleteAzureResources(azureIDs []string, sourceResourceGroup string, sourceSubscriptionID string) {
-	if *dryRunFlag {
-		fmt.Println("\nThe Azure delete actions when \"-dry-run=false\" are similar to the scripted action below:")
-		fmt.Printf(AzureCLI("  az resource delete --ids '%s'"), strings.Join(azureIDs, " "))
+// createTargetPlan returns the target resource group to create for a saved plan.
+func createTargetPlan() *plan.ResourceGroup {
+	if !*createTargetFlag {
+		return nil
+	}
+	return &plan.ResourceGroup{Location: *targetLocationFlag, Tags: targetTags, Address: *importTargetFlag}
+}
 
-		return
+// tagsArgument formats the tags as `--tags` argument of the az cli.
+func tagsArgument(tags azure.Tags) string {
+	if len(tags) == 0 {
+		return ""
 	}
+	var args []string
+	for k, v := range tags {
+		args = append(args, fmt.Sprintf("'%s=%s'", k, v))
+	}
+	sort.Strings(args)
+	return " --tags " + strings.Join(args, " ")
+}
 
-	resourceClient := resources.NewClient(sourceSubscriptionID)
-	authorizer, err := auth.NewAuthorizerFromCLI()
-	if err == nil {
-		resourceClient.Authorizer = authorizer
+// listLocks returns the management locks which block the move: locks on the source and target resource groups and on
+// the resources which are moved or deleted. Locks on the subscriptions can't be removed by aztfmove, these stop the move.
+func listLocks(creds *azure.Credentials, resourceInstances state.ResourcesInstanceSummary, createTarget bool) []azure.Lock {
+	type group struct{ subscriptionID, resourceGroup string }
+	var groups []group
+	scopes := []string{azureid.ResourceGroupID(*targetSubscriptionFlag, *targetResourceGroupFlag)}
+	// a target resource group which is created yet has no locks
+	if !createTarget {
+		groups = append(groups, group{*targetSubscriptionFlag, *targetResourceGroupFlag})
+	}
+	for _, g := range resourceInstances.MoveGroups(stateConfig) {
+		groups = append(groups, group{g.SubscriptionID, g.ResourceGroup})
+		scopes = append(scopes, azureid.ResourceGroupID(g.SubscriptionID, g.ResourceGroup))
+	}
+	var locked []string
+	for _, r := range resourceInstances {
+		switch r.Category(stateConfig) {
+		case state.Movable, state.OnlyMovedInTF, state.Blocking:
+			locked = append(locked, r.AzureID)
+			if r.Category(stateConfig) == state.Blocking && r.ResourceGroup != "" {
+				groups = append(groups, group{r.SubscriptionID, r.ResourceGroup})
+			}
+		}
 	}
-	for _, id := range azureIDs {
-		ctx, cancel := context.WithTimeout(context.Background(), 60*60*time.Second)
-		defer cancel()
 
-		// TODO: API Version hardcoded as only one category yet implemented
-		future, err := resourceClient.DeleteByID(ctx, id, "2021-02-01")
+	var locks, subscriptionLocks []azure.Lock
+	seen := map[string]bool{}
+	for _, g := range groups {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*60*time.Second)
+		found, err := azure.ListLocks(ctx, creds.LocksClient(g.subscriptionID), g.resourceGroup)
+		cancel()
 		if err != nil {
-			fmt.Printf("\n%s cannot delete resources: %v", Fata("Error:"), err)
+			fmt.Printf("%s management locks in resource group %s cannot be listed: %v\n", Fata("Error:"), g.resourceGroup, err)
 			os.Exit(1)
 		}
-		err = future.WaitForCompletionRef(ctx, resourceClient.Client)
-		if err != nil {
-			fmt.Printf("\n%s cannot get the delete future response: %v", Fata("Error:"), err)
-			os.Exit(1)
+		for _, lock := range found {
+			if seen[strings.ToLower(lock.ID)] {
+				continue
+			}
+			seen[strings.ToLower(lock.ID)] = true
+
+			if _, resourceGroup, ok := azureid.Scope(lock.Scope()); ok && resourceGroup == "" {
+				subscriptionLocks = append(subscriptionLocks, lock)
+				continue
+			}
+			if containsID(scopes, lock.Scope()) || withinAny(locked, lock.Scope()) {
+				locks = append(locks, lock)
+			}
 		}
 	}
 
+	if len(subscriptionLocks) > 0 {
+		fmt.Printf("\n%s management locks on the subscription are not removed by aztfmove, remove these before the move:\n", Warn("Warning:"))
+		for _, lock := range subscriptionLocks {
+			fmt.Println(" -", lock)
+		}
+	}
+	return locks
 }
 
-func moveAzureResources(azureIDs []string, sourceResourceGroup string, sourceSubscriptionID string, targetSubscriptionID string, targetResourceGroup string) {
-	if *dryRunFlag {
-		fmt.Println("\nThe Azure move actions when \"-dry-run=false\" are similar to the scripted action below:")
-		fmt.Printf(AzureCLI("  az resource move --destination-group '%s' --destination-subscription-id '%s' --ids '%s'"), targetResourceGroup, targetSubscriptionID, strings.Join(azureIDs, " "))
+// withinAny reports whether the ID is one of the IDs or a child resource of one.
+func withinAny(ids []string, id string) bool {
+	for _, v := range ids {
+		if _, ok := azureid.Rescope(id, v, v); ok {
+			return true
+		}
+	}
+	return false
+}
 
+func printLocks(locks []azure.Lock) {
+	if len(locks) == 0 {
 		return
 	}
-
-	fmt.Printf("\nIt can take some time before this is done, don't panic!")
-	targetResourceGroupID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", targetSubscriptionID, targetResourceGroup)
-	moveInfo := resources.MoveInfo{
-		ResourcesProperty:   &azureIDs,
-		TargetResourceGroup: &targetResourceGroupID,
+	if *manageLocksFlag {
+		fmt.Print(Warn("\nManagement locks which are removed during the move and created again afterwards:\n"))
+	} else {
+		fmt.Print(Warn("\nManagement locks which make the move fail, remove these or use \"-manage-locks\":\n"))
+	}
+	for _, lock := range locks {
+		fmt.Println(" -", lock)
 	}
+}
 
-	resourceClient := resources.NewClient(sourceSubscriptionID)
-	authorizer, err := auth.NewAuthorizerFromCLI()
-	if err == nil {
-		resourceClient.Authorizer = authorizer
+func printUnmanaged(unmanaged state.ResourcesInstanceSummary) {
+	if len(unmanaged) == 0 {
+		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 60*60*time.Second)
-	defer cancel()
+	if *includeUnmanagedFlag {
+		fmt.Print(Warn("\nResources which are not in Terraform, the movable ones are moved as well:\n"))
+	} else {
+		fmt.Print(Warn("\nResources which are not in Terraform, these can block the move or should be moved as well with \"-include-unmanaged\":\n"))
+	}
+	movable := unmanaged.Movable(stateConfig)
+	for _, r := range unmanaged {
+		switch {
+		case r.Category(stateConfig) == state.NotSupported:
+			fmt.Printf(" - %s (not supported)\n", r.AzureID)
+		case r.Category(stateConfig) == state.OnlyMovedInTF:
+			fmt.Printf(" - %s (moved along with its parent)\n", r.AzureID)
+		case containsID(movable.MovableOnAzure(stateConfig), r.AzureID):
+			fmt.Printf(" - %s (movable)\n", r.AzureID)
+		default:
+			fmt.Printf(" - %s (unknown move support)\n", r.AzureID)
+		}
+	}
+}
 
-	future, err := resourceClient.MoveResources(ctx, sourceResourceGroup, moveInfo)
+func authenticate() *azure.Credentials {
+	config, err := azure.ConfigFromEnvironment()
 	if err != nil {
-		fmt.Printf("\n%s cannot move resources: %v", Fata("Error:"), err)
+		fmt.Printf("%s %v\n", Fata("Error:"), err)
 		os.Exit(1)
 	}
-	err = future.WaitForCompletionRef(ctx, resourceClient.Client)
+
+	creds, err := azure.NewCredentials(config)
 	if err != nil {
-		fmt.Printf("\n%s cannot get the move future response: %v", Fata("Error:"), err)
+		fmt.Printf("%s cannot authenticate to Azure: %v\n", Fata("Error:"), err)
 		os.Exit(1)
 	}
+	fmt.Printf("\nAuthenticated to Azure using %s.\n", creds.Method)
+
+	return creds
 }
 
-func removeTerraformResources(tfIDs []string) {
+func askConfirmation() {
+	fmt.Print(Good("\nCan you confirm these resources should be moved?"))
 	if *dryRunFlag {
-		fmt.Println("\nThe Terraform actions taken when \"-dry-run=false\" are similar to the scripted actions below:")
-		for _, tfID := range tfIDs {
-			fmt.Println(" #", tfID)
-			fmt.Printf(TerraformCLI("  terraform state rm '%s'\n"), tfID)
-		}
-		return
+		fmt.Print(" (dry-run!)")
 	}
+	fmt.Printf("\nCheck the Azure documentation on moving Azure resources (https://docs.microsoft.com/en-us/azure/azure-resource-manager/management/move-resource-group-and-subscription) for all the details for your specific resources.")
+	fmt.Print(Good("\n\nType 'yes' to confirm: "))
 
-	for _, tfID := range tfIDs {
-		fmt.Println("\n -", tfID)
+	reader := bufio.NewReader(os.Stdin)
+	inputString, err := reader.ReadString('\n')
+	if err != nil {
+		fmt.Printf("%s confirmation of the import errored out\n", Fata("Error:"))
+		os.Exit(1)
+	}
+	inputString = strings.TrimSuffix(inputString, "\n")
+	if inputString != "yes" {
+		fmt.Printf("\nMove is canceled\n")
+		os.Exit(0)
 
-		output, err := state.RemoveInstance(tfID)
-		if err != nil {
-			fmt.Printf("\n%s terraform resource is not removed, %v\n", Fata("Error:"), err)
-			fmt.Println(" ", output)
-			os.Exit(1)
-		}
-		fmt.Printf("\t✓ Removed")
 	}
 }
 
-func reimportTerraformResources(resources map[string]string) {
-	if *dryRunFlag {
-		fmt.Println("\nThe Terraform actions taken when \"-dry-run=false\" are similar to the scripted actions below:")
-		for tfID, newAzureID := range resources {
-			fmt.Println(" #", tfID)
-			fmt.Printf(TerraformCLI("  terraform state rm '%s'\n"), tfID)
-			fmt.Printf(TerraformCLI("  terraform import %s %s '%s' '%s'\n"), strings.Join(tfVarFiles, " "), strings.Join(tfVars, " "), tfID, newAzureID)
+func printDeleteAzureResources(azureIDs []string) {
+	fmt.Println("\nThe Azure delete actions when \"-dry-run=false\" are similar to the scripted action below:")
+	fmt.Printf(AzureCLI("  az resource delete --ids '%s'"), strings.Join(azureIDs, " "))
+}
+
+func printMoveAzureResources(moveGroups []state.MoveGroup, targetSubscriptionID string, targetResourceGroup string) {
+	fmt.Println("\nThe Azure move actions when \"-dry-run=false\" are similar to the scripted action below:")
+	for _, group := range moveGroups {
+		fmt.Printf(AzureCLI("  az resource move --destination-group '%s' --destination-subscription-id '%s' --ids '%s'\n"), targetResourceGroup, targetSubscriptionID, strings.Join(group.AzureIDs, " "))
+	}
+}
+
+// validate lets Azure validate the move of the selected resources to the target resource group, it returns false when
+// Azure reports the move fails.
+func validate(creds *azure.Credentials, resourceInstances state.ResourcesInstanceSummary, locks []azure.Lock, createTarget bool) bool {
+	if createTarget {
+		fmt.Printf("\n%s the move is not validated by Azure, which can't validate a move to target resource group %s before it is created.\n", Warn("Warning:"), *targetResourceGroupFlag)
+		return true
+	}
+	_, azureIDsToDelete := resourceInstances.BlockingMovement(stateConfig)
+	return validateAzureMove(creds, resourceInstances.MoveGroups(stateConfig), len(azureIDsToDelete) > 0 || (*manageLocksFlag && len(locks) > 0), *targetSubscriptionFlag, *targetResourceGroupFlag)
+}
+
+// validateAzureMove lets Azure validate the move before anything is changed. When blocking resources are selected or
+// management locks are removed, they are still present during validation and can cause errors, so these errors are
+// shown as warning only.
+func validateAzureMove(creds *azure.Credentials, moveGroups []state.MoveGroup, blocking bool, targetSubscriptionID string, targetResourceGroup string) bool {
+	if len(moveGroups) == 0 {
+		return true
+	}
+
+	fmt.Print(Azure("\nThe move is validated by Azure."))
+	fmt.Printf("\nIt can take some time before this is done, don't panic!")
+
+	var failed bool
+	for _, group := range moveGroups {
+		resourceClient := creds.ResourcesClient(group.SubscriptionID)
+		ctx, cancel := context.WithTimeout(context.Background(), 60*60*time.Second)
+		err := azure.ValidateMove(ctx, resourceClient, group.ResourceGroup, group.AzureIDs, azureid.ResourceGroupID(targetSubscriptionID, targetResourceGroup))
+		cancel()
+		if err == nil {
+			continue
 		}
+
+		failed = true
+		if blocking && !*validateFlag {
+			fmt.Printf("\n%s Azure validation of the move from resource group %s failed: %v", Warn("Warning:"), group.ResourceGroup, err)
+		} else {
+			fmt.Printf("\n%s Azure validation of the move from resource group %s failed: %v", Fata("Error:"), group.ResourceGroup, err)
+		}
+		printMoveErrorDetails(err)
+	}
+
+	switch {
+	case !failed:
+		fmt.Print(Good("\n\nAzure validated the move successfully.\n"))
+	case blocking && !*validateFlag:
+		fmt.Println("\nBlocking resources and management locks are not removed yet during validation and can cause these errors, the move is continued.")
+	default:
+		return false
+	}
+	return true
+}
+
+func printMoveErrorDetails(err error) {
+	moveErr, ok := err.(*azure.MoveError)
+	if !ok {
 		return
 	}
+	fmt.Println()
+	for _, detail := range moveErr.Details {
+		fmt.Printf(" - %s\n   %s %s\n", detail.Target, Warn(detail.Code+":"), detail.Message)
+	}
+}
 
-	for tfID, newAzureID := range resources {
-		fmt.Println("\n -", tfID)
+func printRemoveTerraformResources(tfIDs []string) {
+	fmt.Println("\nThe Terraform actions taken when \"-dry-run=false\" are similar to the scripted actions below:")
+	for _, tfID := range tfIDs {
+		fmt.Println(" #", tfID)
+		fmt.Printf(TerraformCLI("  terraform state rm '%s'\n"), tfID)
+	}
+}
 
-		output, err := state.RemoveInstance(tfID)
-		if err != nil {
-			fmt.Printf("\n%s terraform resource is not removed, %v\n", Fata("Error:"), err)
-			fmt.Println(" ", output)
-			os.Exit(1)
-		}
-		fmt.Printf("\t✓ Removed")
+func printRewriteTerraformResources(resources map[string]string, references []state.Reference) {
+	fmt.Println("\nThe Terraform actions taken when \"-dry-run=false\" are similar to the scripted actions below:")
+	fmt.Println(TerraformCLI("  terraform state pull > terraform.tfstate.rewrite"))
+	for tfID, newAzureID := range resources {
+		fmt.Printf("  # %s: [id=%s]\n", tfID, newAzureID)
+	}
+	for _, ref := range references {
+		fmt.Printf("  # %s: %s\n", ref, ref.FutureValue)
+	}
+	fmt.Println("  # ... the attributes above are rewritten and the serial is increased")
+	fmt.Println(TerraformCLI("  terraform state push terraform.tfstate.rewrite"))
+}
 
-		output, err = state.ImportInstance(tfID, newAzureID, tfVars, tfVarFiles)
-		if err != nil {
-			fmt.Printf("\n%s terraform resource is not imported, %v\n", Fata("Error:"), err)
-			fmt.Println(" ", output)
-			os.Exit(1)
+// printReimportTerraformResources prints the resources to remove from the Terraform state, the ones in toImport are
+// imported again.
+func printReimportTerraformResources(resources, toImport map[string]string) {
+	fmt.Println("\nThe Terraform actions taken when \"-dry-run=false\" are similar to the scripted actions below:")
+	for tfID, newAzureID := range resources {
+		fmt.Println(" #", tfID)
+		fmt.Printf(TerraformCLI("  terraform state rm '%s'\n"), tfID)
+		if _, ok := toImport[tfID]; !ok {
+			fmt.Println("  # can't be imported, created again with the next 'terraform apply'")
+			continue
 		}
-		fmt.Printf("\t✓ Imported")
+		fmt.Printf(TerraformCLI("  terraform import %s %s '%s' '%s'\n"), strings.Join(tfVarFiles, " "), strings.Join(tfVars, " "), tfID, newAzureID)
 	}
 }
@@ -0,0 +1,97 @@
+// Package plan describes a move in a versioned document, for pipelines which gate on or archive what aztfmove does.
+package plan
+
+import (
+	"github.com/aristosvo/aztfmove/state"
+)
+
+// FormatVersion is increased on every change which is not backwards compatible.
+const FormatVersion = 1
+
+// Action is a step taken for a resource instance.
+type Action string
+
+const (
+	// Delete deletes the resource in Azure, it is recreated by Terraform afterwards.
+	Delete Action = "delete"
+	// Remove removes the instance from the Terraform state.
+	Remove Action = "remove"
+	// Move moves the resource in Azure.
+	Move Action = "move"
+	// Reimport removes the instance from the Terraform state and imports it with the ID after the move.
+	Reimport Action = "reimport"
+	// Rewrite rewrites the instance in the Terraform state, see `-rewrite-state`.
+	Rewrite Action = "rewrite"
+	// ImportBlock removes the instance from the Terraform state and writes an import block, see `-emit-import-blocks`.
+	ImportBlock Action = "import-block"
+	// Recreate creates a deleted blocking resource again in Azure and imports it, see `-recreate-blocking=arm`.
+	Recreate Action = "recreate"
+	// Apply creates a deleted blocking resource again with `terraform apply -target`, see `-recreate-blocking=terraform`.
+	Apply Action = "apply"
+)
+
+// Scope is a subscription and resource group, either can be "*" for a source selecting all.
+type Scope struct {
+	SubscriptionID string `json:"subscription_id"`
+	ResourceGroup  string `json:"resource_group"`
+}
+
+type Resource struct {
+	TerraformID    string         `json:"terraform_id"`
+	Type           string         `json:"type"`
+	AzureID        string         `json:"azure_id"`
+	FutureAzureID  string         `json:"future_azure_id"`
+	SubscriptionID string         `json:"subscription_id,omitempty"`
+	ResourceGroup  string         `json:"resource_group,omitempty"`
+	Category       state.Category `json:"category"`
+	Actions        []Action       `json:"actions"`
+}
+
+type Plan struct {
+	FormatVersion int        `json:"format_version"`
+	Source        Scope      `json:"source"`
+	Target        Scope      `json:"target"`
+	Resources     []Resource `json:"resources"`
+	// References are the attributes referring to the moved resources, see state.References.
+	References []state.Reference `json:"references"`
+}
+
+// New describes the move of the selected resources, correct is the action which corrects the Terraform state:
+// Reimport, Rewrite or ImportBlock. recreate is the action which recreates deleted blocking resources, Recreate or
+// Apply, or "" when these are not recreated.
+func New(config *state.Config, resourceInstances state.ResourcesInstanceSummary, references []state.Reference, source, target Scope, correct, recreate Action) Plan {
+	p := Plan{FormatVersion: FormatVersion, Source: source, Target: target, Resources: []Resource{}, References: []state.Reference{}}
+	p.References = append(p.References, references...)
+	for _, r := range resourceInstances {
+		p.Resources = append(p.Resources, Resource{
+			TerraformID:    r.TerraformID,
+			Type:           r.Type,
+			AzureID:        r.AzureID,
+			FutureAzureID:  r.FutureAzureID,
+			SubscriptionID: r.SubscriptionID,
+			ResourceGroup:  r.ResourceGroup,
+			Category:       r.Category(config),
+			Actions:        actions(config, r, correct, recreate),
+		})
+	}
+	return p
+}
+
+func actions(config *state.Config, r state.ResourceInstanceSummary, correct, recreate Action) []Action {
+	switch r.Category(config) {
+	case state.Movable:
+		return []Action{Move, correct}
+	case state.OnlyMovedInTF:
+		if !r.Importable(config) && correct != Rewrite {
+			// resources which can't be imported are created again by Terraform
+			return []Action{Remove}
+		}
+		return []Action{correct}
+	case state.Blocking:
+		if recreate != "" {
+			return []Action{Delete, Remove, recreate}
+		}
+		return []Action{Delete, Remove}
+	}
+	return []Action{}
+}
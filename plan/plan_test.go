@@ -0,0 +1,77 @@
+package plan
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aristosvo/aztfmove/state"
+)
+
+func TestNew(t *testing.T) {
+	config := state.DefaultConfig()
+	summary := state.ResourcesInstanceSummary{
+		{
+			AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app/networkConfig/virtualNetwork",
+			TerraformID:    "azurerm_app_service_virtual_network_swift_connection.app",
+			FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Web/sites/app/networkConfig/virtualNetwork",
+			Type:           "azurerm_app_service_virtual_network_swift_connection",
+			SubscriptionID: "00000000-0000-0000-0000-000000000000",
+			ResourceGroup:  "myresourcegroup",
+		},
+		{
+			AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app",
+			TerraformID:    "azurerm_app_service.app",
+			FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Web/sites/app",
+			Type:           "azurerm_app_service",
+			SubscriptionID: "00000000-0000-0000-0000-000000000000",
+			ResourceGroup:  "myresourcegroup",
+		},
+		{
+			AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			TerraformID:    "azurerm_subnet.subnet",
+			FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			Type:           "azurerm_subnet",
+			SubscriptionID: "00000000-0000-0000-0000-000000000000",
+			ResourceGroup:  "myresourcegroup",
+		},
+		{
+			AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup",
+			TerraformID:    "azurerm_resource_group.rg",
+			FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2",
+			Type:           "azurerm_resource_group",
+			SubscriptionID: "00000000-0000-0000-0000-000000000000",
+			ResourceGroup:  "myresourcegroup",
+		},
+	}
+	source := Scope{SubscriptionID: "00000000-0000-0000-0000-000000000000", ResourceGroup: "*"}
+	target := Scope{SubscriptionID: "00000000-0000-0000-0000-000000000000", ResourceGroup: "myresourcegroup2"}
+
+	p := New(config, summary, nil, source, target, Rewrite, Apply)
+
+	var got [][]Action
+	var categories []state.Category
+	for _, r := range p.Resources {
+		got = append(got, r.Actions)
+		categories = append(categories, r.Category)
+	}
+	wanted := [][]Action{{Delete, Remove, Apply}, {Move, Rewrite}, {Rewrite}, {}}
+	if !reflect.DeepEqual(got, wanted) {
+		t.Errorf("got %v wanted %v", got, wanted)
+	}
+	wantedCategories := []state.Category{state.Blocking, state.Movable, state.OnlyMovedInTF, state.NotSupported}
+	if !reflect.DeepEqual(categories, wantedCategories) {
+		t.Errorf("got %v wanted %v", categories, wantedCategories)
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(New(config, nil, nil, source, target, Reimport, ""))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wanted := `{"format_version":1,"source":{"subscription_id":"00000000-0000-0000-0000-000000000000","resource_group":"*"},"target":{"subscription_id":"00000000-0000-0000-0000-000000000000","resource_group":"myresourcegroup2"},"resources":[],"references":[]}`
+		if string(data) != wanted {
+			t.Errorf("got %s wanted %s", data, wanted)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aristosvo/aztfmove/state"
+)
+
+// ResourceGroup is the target resource group to create, with the address to import it to when set.
+type ResourceGroup struct {
+	Location string            `json:"location"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Address  string            `json:"address,omitempty"`
+}
+
+// Saved is a plan written by `aztfmove plan -out`, which `aztfmove apply` executes as it was reviewed.
+type Saved struct {
+	FormatVersion int   `json:"format_version"`
+	Source        Scope `json:"source"`
+	Target        Scope `json:"target"`
+	// Correct is the action which corrects the Terraform state, ImportBlocksPath is set for ImportBlock.
+	Correct          Action `json:"correct"`
+	ImportBlocksPath string `json:"import_blocks_path,omitempty"`
+	// RecreateBlocking is how deleted blocking resources are recreated, see `-recreate-blocking`.
+	RecreateBlocking string `json:"recreate_blocking"`
+	// ManageLocks is set when management locks are removed and created again, see `-manage-locks`.
+	ManageLocks bool `json:"manage_locks,omitempty"`
+	// CreateTarget is set when the target resource group is created, see `-create-target-resource-group`.
+	CreateTarget *ResourceGroup `json:"create_target,omitempty"`
+
+	// Serial and Lineage identify the state the plan is made for.
+	Serial  int64  `json:"serial"`
+	Lineage string `json:"lineage"`
+
+	Vars      state.ArrayVars                `json:"vars,omitempty"`
+	VarFiles  state.ArrayVarFiles            `json:"var_files,omitempty"`
+	Resources state.ResourcesInstanceSummary `json:"resources"`
+	// Categories are the categories of the resources when the plan was made, in the same order. These depend on the
+	// type config, which can change before the plan is applied.
+	Categories []state.Category `json:"categories"`
+}
+
+// Read reads a plan saved by Write.
+func Read(path string) (Saved, error) {
+	var saved Saved
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return saved, fmt.Errorf("plan %s cannot be read: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return saved, fmt.Errorf("plan %s cannot be parsed: %v", path, err)
+	}
+	if saved.FormatVersion != FormatVersion {
+		return saved, fmt.Errorf("plan %s has format version %d, only version %d is supported", path, saved.FormatVersion, FormatVersion)
+	}
+	return saved, nil
+}
+
+// Write saves the plan. Variables are saved as well, so the file should be handled like the state itself.
+func (s Saved) Write(path string) error {
+	s.FormatVersion = FormatVersion
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("plan %s cannot be written: %v", path, err)
+	}
+	return nil
+}
+
+// Check returns an error when the state changed since the plan was made.
+func (s Saved) Check(tfstate state.TerraformState) error {
+	if tfstate.Lineage != s.Lineage {
+		return fmt.Errorf("the plan is made for a state with lineage %s, the current state has lineage %s", s.Lineage, tfstate.Lineage)
+	}
+	if tfstate.Serial != s.Serial {
+		return fmt.Errorf("the state changed since the plan was made, serial %d is now %d", s.Serial, tfstate.Serial)
+	}
+	return nil
+}
+
+// Categories returns the category of every resource, see Saved.Categories.
+func Categories(config *state.Config, resourceInstances state.ResourcesInstanceSummary) []state.Category {
+	categories := []state.Category{}
+	for _, r := range resourceInstances {
+		categories = append(categories, r.Category(config))
+	}
+	return categories
+}
+
+// CheckCategories returns an error when a resource is handled differently than when the plan was made, i.e. because
+// another type config is used.
+func (s Saved) CheckCategories(config *state.Config) error {
+	if len(s.Categories) != len(s.Resources) {
+		return fmt.Errorf("the plan has %d categories for %d resources", len(s.Categories), len(s.Resources))
+	}
+	for i, r := range s.Resources {
+		if category := r.Category(config); category != s.Categories[i] {
+			address := r.TerraformID
+			if r.Unmanaged {
+				address = r.AzureID
+			}
+			return fmt.Errorf("%s is planned as %s, but is %s with the current type config", address, s.Categories[i], category)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package plan
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aristosvo/aztfmove/state"
+)
+
+func TestSaved(t *testing.T) {
+	config := state.DefaultConfig()
+	saved := Saved{
+		Source:  Scope{SubscriptionID: "00000000-0000-0000-0000-000000000000", ResourceGroup: "myresourcegroup"},
+		Target:  Scope{SubscriptionID: "00000000-0000-0000-0000-000000000000", ResourceGroup: "myresourcegroup2"},
+		Correct: Reimport,
+		Serial:  12,
+		Lineage: "4f3a2b1c-0000-0000-0000-000000000000",
+		Vars:    state.ArrayVars{"-var", "location=westeurope"},
+		Resources: state.ResourcesInstanceSummary{
+			{
+				AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app",
+				TerraformID:    "azurerm_app_service.app",
+				FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Web/sites/app",
+				Type:           "azurerm_app_service",
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				ResourceGroup:  "myresourcegroup",
+				Dependencies:   []string{"azurerm_app_service_plan.plan"},
+			},
+		},
+		Categories: []state.Category{state.Movable},
+	}
+
+	path := filepath.Join(t.TempDir(), "aztfmove.plan")
+	if err := saved.Write(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := Read(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	saved.FormatVersion = FormatVersion
+	if !reflect.DeepEqual(got, saved) {
+		t.Errorf("got %v wanted %v", got, saved)
+	}
+
+	t.Run("Same state", func(t *testing.T) {
+		if err := got.Check(state.TerraformState{Serial: 12, Lineage: saved.Lineage}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Changed state", func(t *testing.T) {
+		if err := got.Check(state.TerraformState{Serial: 13, Lineage: saved.Lineage}); err == nil {
+			t.Errorf("expected an error for a changed serial")
+		}
+	})
+
+	t.Run("Same categories", func(t *testing.T) {
+		if err := got.CheckCategories(config); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Changed categories", func(t *testing.T) {
+		changed := got
+		changed.Categories = []state.Category{state.NotSupported}
+		if err := changed.CheckCategories(config); err == nil {
+			t.Errorf("expected an error for a changed category")
+		}
+	})
+
+	t.Run("Other state", func(t *testing.T) {
+		if err := got.Check(state.TerraformState{Serial: 12, Lineage: "other"}); err == nil {
+			t.Errorf("expected an error for a different lineage")
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Addresses are Terraform addresses or patterns, set as flag with one address per flag.
+type Addresses []string
+
+func (a *Addresses) String() string {
+	return strings.Join(*a, ",")
+}
+
+func (a *Addresses) Set(value string) error {
+	*a = append(*a, value)
+	return nil
+}
+
+// Selection selects resource instances by their Terraform address.
+//
+// Targets and Excludes accept the address syntax of `terraform plan -target`: a module, like `module.app` or
+// `module.app["eu"]`, selects all resources in it including its child modules, a resource, like
+// `module.app["eu"].azurerm_storage_account.sa`, selects all its instances and an instance, like
+// `azurerm_storage_account.sa[0]`, only that instance. In patterns `*` matches any text, including dots and instance
+// keys, and `?` a single character. A pattern between slashes, like `/^module\.app\[.*\]$/`, is a regular expression.
+type Selection struct {
+	// Resource selects a single resource by its exact address, "*" or "" selects all.
+	Resource string
+	// Module selects the resources in a module and its child modules, like `module.storage`, which includes
+	// `module.storage[0]` and `module.storage.module.containers`, or `module.storage["eu"]` for one module instance.
+	// "*" or "" selects all.
+	Module string
+	// Targets select the instances matching any of the addresses, all instances are selected when there are none.
+	Targets Addresses
+	// Excludes leave out the instances matching any of the addresses, also when these are targeted.
+	Excludes Addresses
+	// Tags select the instances with matching tags, see TagFilter.
+	Tags TagFilter
+	// TagSource returns the tags of an instance to match Tags against, i.e. the tags in Azure. When nil, the tags in the
+	// Terraform state are used. An error stops the selection.
+	TagSource func(r Resource, instance Instance) (map[string]string, error)
+}
+
+// matcher matches an address against a single target or exclude.
+type matcher func(address string) bool
+
+func (s Selection) compile() (targets, excludes []matcher, err error) {
+	for _, target := range s.Targets {
+		m, err := compileAddress(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		targets = append(targets, m)
+	}
+	for _, exclude := range s.Excludes {
+		m, err := compileAddress(exclude)
+		if err != nil {
+			return nil, nil, err
+		}
+		excludes = append(excludes, m)
+	}
+	return targets, excludes, nil
+}
+
+// compileAddress returns the matcher for an address, a glob pattern or a regular expression between slashes.
+func compileAddress(address string) (matcher, error) {
+	if len(address) > 1 && strings.HasPrefix(address, "/") && strings.HasSuffix(address, "/") {
+		re, err := regexp.Compile(address[1 : len(address)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %s: %v", address, err)
+		}
+		return re.MatchString, nil
+	}
+	return func(s string) bool { return glob(address, s) }, nil
+}
+
+// glob reports whether s matches the pattern, in which `*` matches any text and `?` a single character. Other
+// characters, like the brackets of instance keys, match literally.
+func glob(pattern, s string) bool {
+	p, t := []rune(pattern), []rune(s)
+	star, match := -1, 0
+	i, k := 0, 0
+	for k < len(t) {
+		switch {
+		case i < len(p) && (p[i] == '?' || p[i] == t[k]):
+			i++
+			k++
+		case i < len(p) && p[i] == '*':
+			star, match = i, k
+			i++
+		case star != -1:
+			i = star + 1
+			match++
+			k = match
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
+
+// selects reports whether the instance is selected by the targets and tags and not left out by the excludes.
+func (s Selection) selects(targets, excludes []matcher, r Resource, instance Instance) (bool, error) {
+	if s.Resource != "" && s.Resource != "*" && r.ID() != s.Resource {
+		return false, nil
+	}
+	if s.Module != "" && s.Module != "*" && !contains(moduleAddresses(r.Module), s.Module) {
+		return false, nil
+	}
+
+	addresses := instanceAddresses(r, instance)
+	if len(targets) > 0 && !matchesAny(targets, addresses) {
+		return false, nil
+	}
+	if matchesAny(excludes, addresses) {
+		return false, nil
+	}
+
+	if len(s.Tags) == 0 {
+		return true, nil
+	}
+	if s.TagSource != nil {
+		tags, err := s.TagSource(r, instance)
+		if err != nil {
+			return false, err
+		}
+		return s.Tags.Matches(tags), nil
+	}
+	return s.Tags.Matches(instance.Attributes.Tags()), nil
+}
+
+func matchesAny(matchers []matcher, addresses []string) bool {
+	for _, m := range matchers {
+		for _, address := range addresses {
+			if m(address) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// instanceAddresses returns every address which selects the instance: the modules it's in, its resource and the
+// instance itself.
+func instanceAddresses(r Resource, instance Instance) []string {
+	return append(moduleAddresses(r.Module), r.ID(), instance.ID(r))
+}
+
+// moduleAddresses returns the addresses of a module and its parent modules, for modules with an instance key with
+// and without the key. For `module.app["eu"].module.network` these are `module.app`, `module.app["eu"]` and
+// `module.app["eu"].module.network`.
+func moduleAddresses(module string) []string {
+	var addresses []string
+	prefix := ""
+	steps := splitAddress(module)
+	for i := 0; i+1 < len(steps); i += 2 {
+		name := steps[i+1]
+		if idx := strings.Index(name, "["); idx != -1 {
+			addresses = append(addresses, prefix+"module."+name[:idx])
+		}
+		prefix += "module." + name
+		addresses = append(addresses, prefix)
+		prefix += "."
+	}
+	return addresses
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAddress splits an address on the dots which are not in an instance key.
+func splitAddress(address string) []string {
+	if address == "" {
+		return nil
+	}
+
+	var parts []string
+	var quoted, escaped bool
+	start := 0
+	for i, c := range address {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			parts = append(parts, address[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, address[start:])
+}
+
+// Unmatched returns the targets and excludes of the selection which match no instance of an azurerm or azapi resource.
+func (tfstate TerraformState) Unmatched(config *Config, selection Selection) ([]string, error) {
+	var unmatched []string
+	for _, address := range append(append(Addresses{}, selection.Targets...), selection.Excludes...) {
+		m, err := compileAddress(address)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := false
+		for _, r := range tfstate.Resources {
+			if !r.isAccepted(config) {
+				continue
+			}
+			for _, instance := range r.Instances {
+				if matchesAny([]matcher{m}, instanceAddresses(r, instance)) {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, address)
+		}
+	}
+	return unmatched, nil
+}
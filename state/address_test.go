@@ -0,0 +1,133 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelection(t *testing.T) {
+	config := DefaultConfig()
+	azurerm := "provider[\"registry.terraform.io/hashicorp/azurerm\"]"
+	state := TerraformState{
+		Resources: []Resource{
+			{
+				Provider: azurerm,
+				Type:     "azurerm_storage_account",
+				Name:     "sa",
+				Mode:     "managed",
+				Instances: []Instance{
+					{IndexKey: float64(0), Attributes: Attributes{ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/sa0"}},
+					{IndexKey: float64(1), Attributes: Attributes{ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/sa1"}},
+				},
+			},
+			{
+				Provider: azurerm,
+				Module:   "module.app[\"eu.west\"]",
+				Type:     "azurerm_storage_account",
+				Name:     "sa",
+				Mode:     "managed",
+				Instances: []Instance{
+					{Attributes: Attributes{ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/saeu"}},
+				},
+			},
+			{
+				Provider: azurerm,
+				Module:   "module.app[\"eu.west\"].module.network",
+				Type:     "azurerm_virtual_network",
+				Name:     "vnet",
+				Mode:     "managed",
+				Instances: []Instance{
+					{Attributes: Attributes{ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Network/virtualNetworks/vnet"}},
+				},
+			},
+			{
+				Provider: azurerm,
+				Module:   "module.app[\"us\"]",
+				Type:     "azurerm_storage_account",
+				Name:     "sa",
+				Mode:     "managed",
+				Instances: []Instance{
+					{Attributes: Attributes{ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/saus"}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		selection Selection
+		want      []string
+	}{
+		{
+			name:      "Instance",
+			selection: Selection{Targets: Addresses{"azurerm_storage_account.sa[1]"}},
+			want:      []string{"azurerm_storage_account.sa[1]"},
+		},
+		{
+			name:      "Module with instance key and child modules",
+			selection: Selection{Targets: Addresses{"module.app[\"eu.west\"]"}},
+			want:      []string{"module.app[\"eu.west\"].azurerm_storage_account.sa", "module.app[\"eu.west\"].module.network.azurerm_virtual_network.vnet"},
+		},
+		{
+			name:      "Module without instance key",
+			selection: Selection{Targets: Addresses{"module.app"}, Excludes: Addresses{"module.app[\"eu.west\"].module.network"}},
+			want:      []string{"module.app[\"eu.west\"].azurerm_storage_account.sa", "module.app[\"us\"].azurerm_storage_account.sa"},
+		},
+		{
+			name:      "Module with child modules",
+			selection: Selection{Module: "module.app"},
+			want:      []string{"module.app[\"eu.west\"].azurerm_storage_account.sa", "module.app[\"eu.west\"].module.network.azurerm_virtual_network.vnet", "module.app[\"us\"].azurerm_storage_account.sa"},
+		},
+		{
+			name:      "Module instance",
+			selection: Selection{Module: "module.app[\"eu.west\"].module.network"},
+			want:      []string{"module.app[\"eu.west\"].module.network.azurerm_virtual_network.vnet"},
+		},
+		{
+			name:      "Module is no prefix of the name",
+			selection: Selection{Module: "module.ap"},
+		},
+		{
+			name:      "Glob",
+			selection: Selection{Targets: Addresses{"*.azurerm_storage_account.sa", "azurerm_storage_account.sa[?]"}, Excludes: Addresses{"*[0]"}},
+			want:      []string{"azurerm_storage_account.sa[1]", "module.app[\"eu.west\"].azurerm_storage_account.sa", "module.app[\"us\"].azurerm_storage_account.sa"},
+		},
+		{
+			name:      "Regular expression",
+			selection: Selection{Targets: Addresses{"/^module\\.app\\[\"us\"\\]\\./"}},
+			want:      []string{"module.app[\"us\"].azurerm_storage_account.sa"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := state.Filter(config, tt.selection, "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000000")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, r := range summary {
+				got = append(got, r.TerraformID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v wanted %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Unmatched", func(t *testing.T) {
+		got, err := state.Unmatched(config, Selection{Targets: Addresses{"module.app", "module.db"}, Excludes: Addresses{"azurerm_storage_account.sa[2]"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wanted := []string{"module.db", "azurerm_storage_account.sa[2]"}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+
+	t.Run("Invalid regular expression", func(t *testing.T) {
+		if _, err := state.Filter(config, Selection{Targets: Addresses{"/module.app[/"}}, "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000000"); err == nil {
+			t.Errorf("expected an error for an invalid regular expression")
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package state
+
+import (
+	"strings"
+)
+
+// azapiType returns the ARM type and API version of an instance of the azapi provider, from its `type` attribute like
+// `Microsoft.Storage/storageAccounts@2023-01-01`. Instances of other providers return "".
+func (i Instance) azapiType(r Resource) (armType, apiVersion string) {
+	if !strings.HasPrefix(r.Type, "azapi_") {
+		return "", ""
+	}
+	value, ok := i.Attributes.Values["type"].(string)
+	if !ok {
+		return "", ""
+	}
+	armType, apiVersion, _ = strings.Cut(value, "@")
+	return armType, apiVersion
+}
+
+// lookupARMType returns what is known about the azurerm types of an ARM type, like `Microsoft.Resources/resourceGroups`
+// of `azurerm_resource_group`. This way azapi resources are handled like the azurerm resources of the same ARM type.
+func (c *Config) lookupARMType(armType string) (TypeInfo, bool) {
+	if armType == "" {
+		return TypeInfo{}, false
+	}
+	for _, info := range c.types {
+		if strings.EqualFold(info.ARMType, armType) {
+			return info, true
+		}
+	}
+	return TypeInfo{}, false
+}
@@ -0,0 +1,113 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAzapi(t *testing.T) {
+	config := DefaultConfig()
+	azapi := "provider[\"registry.terraform.io/azure/azapi\"]"
+	resourceGroupID := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup"
+	storageAccountID := resourceGroupID + "/providers/Microsoft.Storage/storageAccounts/sa"
+	state := TerraformState{
+		Resources: []Resource{
+			{
+				Provider: azapi,
+				Type:     "azapi_resource",
+				Name:     "rg",
+				Mode:     "managed",
+				Instances: []Instance{
+					{Attributes: Attributes{ID: resourceGroupID, Values: map[string]interface{}{"type": "Microsoft.Resources/resourceGroups@2021-04-01", "parent_id": "/subscriptions/00000000-0000-0000-0000-000000000000"}}},
+				},
+			},
+			{
+				Provider: azapi,
+				Type:     "azapi_resource",
+				Name:     "sa",
+				Mode:     "managed",
+				Instances: []Instance{
+					{Attributes: Attributes{ID: storageAccountID, Values: map[string]interface{}{"type": "Microsoft.Storage/storageAccounts@2023-01-01", "parent_id": resourceGroupID}}},
+				},
+			},
+			{
+				Provider: azapi,
+				Type:     "azapi_update_resource",
+				Name:     "sa",
+				Mode:     "managed",
+				Instances: []Instance{
+					{Attributes: Attributes{ID: storageAccountID, Values: map[string]interface{}{"type": "Microsoft.Storage/storageAccounts@2023-01-01", "resource_id": storageAccountID}}},
+				},
+			},
+		},
+	}
+
+	summary, err := state.Filter(config, Selection{}, "myresourcegroup", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000000")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("Category", func(t *testing.T) {
+		var got []Category
+		for _, r := range summary {
+			got = append(got, r.Category(config))
+		}
+		wanted := []Category{NotSupported, Movable, OnlyMovedInTF}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+
+	t.Run("ARM type and API version", func(t *testing.T) {
+		got := []string{summary[1].ARMType, summary[1].APIVersion, summary[1].DeleteAPIVersion(config)}
+		wanted := []string{"Microsoft.Storage/storageAccounts", "2023-01-01", "2023-01-01"}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		got := summary.ToImport(config)
+		wanted := map[string]string{"azapi_resource.sa": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/sa"}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+
+	t.Run("References", func(t *testing.T) {
+		got := state.References(config, summary)
+		wanted := []Reference{
+			{Address: "azapi_resource.sa", Attribute: "parent_id", Value: resourceGroupID, FutureValue: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2"},
+			{Address: "azapi_update_resource.sa", Attribute: "resource_id", Value: storageAccountID, FutureValue: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/sa"},
+		}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+}
+
+func TestAzapiCategory(t *testing.T) {
+	config := DefaultConfig()
+	// the bastion host can't be moved, so it being in the target resource group already is no reason to stop
+	state := TerraformState{
+		Resources: []Resource{
+			{
+				Provider: "provider[\"registry.terraform.io/azure/azapi\"]",
+				Type:     "azapi_resource",
+				Name:     "bastion",
+				Mode:     "managed",
+				Instances: []Instance{
+					{Attributes: Attributes{ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Network/bastionHosts/bastion", Values: map[string]interface{}{"type": "Microsoft.Network/bastionHosts@2023-04-01"}}},
+				},
+			},
+		},
+	}
+
+	summary, err := state.Filter(config, Selection{}, "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000000")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := summary.NotSupported(config); !reflect.DeepEqual(got, []string{"azapi_resource.bastion"}) {
+		t.Errorf("got %v wanted %v", got, []string{"azapi_resource.bastion"})
+	}
+}
@@ -0,0 +1,27 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Backup pulls the current state and writes it to a timestamped file in dir, named like the backups Terraform makes itself.
+// The state is written exactly as pulled, so it can be restored with `terraform state push`.
+func Backup(ctx context.Context, tf Terraform, dir string, now time.Time) (string, error) {
+	tfstate, err := tf.Pull(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(tfstate.Raw()) == 0 {
+		return "", fmt.Errorf("the pulled Terraform state is empty, no backup is written")
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("terraform.tfstate.%s.aztfmove.backup", now.UTC().Format("20060102T150405Z")))
+	if err := os.WriteFile(path, tfstate.Raw(), 0600); err != nil {
+		return "", fmt.Errorf("backup of the Terraform state cannot be written: %v", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,29 @@
+package state
+
+// Config is what is known about the resource types and providers of a move: the type config, the move support matrix,
+// the accepted provider sources and the subscriptions of provider configurations. It's passed to Filter and Category,
+// so a `-type-config` or `-provider-source` only applies to the move it's given for.
+type Config struct {
+	types                 map[string]TypeInfo
+	moveSupport           map[string]MoveSupport
+	providerSources       []string
+	providerSubscriptions map[string]string
+}
+
+// DefaultConfig returns the embedded type config and move support matrix, accepting the azurerm and azapi providers of
+// the Terraform and OpenTofu registries.
+func DefaultConfig() *Config {
+	config := &Config{
+		types:                 map[string]TypeInfo{},
+		moveSupport:           map[string]MoveSupport{},
+		providerSources:       []string{"registry.terraform.io/hashicorp/azurerm", "registry.opentofu.org/hashicorp/azurerm", "registry.terraform.io/azure/azapi", "registry.opentofu.org/azure/azapi"},
+		providerSubscriptions: map[string]string{},
+	}
+	for resourceType, info := range embeddedTypeConfig {
+		config.types[resourceType] = info
+	}
+	for armType, support := range embeddedMoveSupportMatrix {
+		config.moveSupport[armType] = support
+	}
+	return config
+}
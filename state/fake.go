@@ -0,0 +1,273 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// FakeTerraform is an in-memory Terraform, meant to test move flows without a `terraform` binary.
+// Resources stay known when all their instances are removed, as they would still be in the configuration.
+type FakeTerraform struct {
+	State TerraformState
+	// Calls records every command in the form it would have on the command line, i.e. "state rm <address>".
+	Calls []string
+	// Errors makes the command with the same form as in Calls fail with the given error.
+	Errors map[string]error
+}
+
+func NewFakeTerraform(tfstate TerraformState) *FakeTerraform {
+	return &FakeTerraform{State: tfstate, Errors: map[string]error{}}
+}
+
+func (f *FakeTerraform) call(command string) error {
+	f.Calls = append(f.Calls, command)
+	return f.Errors[command]
+}
+
+func (f *FakeTerraform) Pull(ctx context.Context) (TerraformState, error) {
+	if err := f.call("state pull"); err != nil {
+		return TerraformState{}, err
+	}
+
+	// a round trip through JSON returns a copy, with the raw document like a real pull
+	data, err := json.Marshal(f.State)
+	if err != nil {
+		return TerraformState{}, err
+	}
+	var tfstate TerraformState
+	err = tfstate.parseState(data)
+	return tfstate, err
+}
+
+func (f *FakeTerraform) Remove(ctx context.Context, address string) error {
+	if err := f.call(fmt.Sprintf("state rm %s", address)); err != nil {
+		return err
+	}
+
+	if _, ok := f.take(address); !ok {
+		return fmt.Errorf("invalid target address: no matching objects found for %s", address)
+	}
+	return nil
+}
+
+func (f *FakeTerraform) Import(ctx context.Context, address, id string) error {
+	if err := f.call(fmt.Sprintf("import %s %s", address, id)); err != nil {
+		return err
+	}
+
+	if f.find(address) != nil {
+		return fmt.Errorf("resource already managed by Terraform: %s", address)
+	}
+
+	resourceAddress, key, err := splitInstanceAddress(address)
+	if err != nil {
+		return err
+	}
+	for i, r := range f.State.Resources {
+		if r.ID() == resourceAddress {
+			f.State.Resources[i].Instances = append(r.Instances, Instance{IndexKey: key, Attributes: Attributes{ID: id}})
+			return nil
+		}
+	}
+	return fmt.Errorf("configuration for import target does not exist: %s", address)
+}
+
+func (f *FakeTerraform) Show(ctx context.Context) (*tfjson.State, error) {
+	if err := f.call("show -json"); err != nil {
+		return nil, err
+	}
+
+	root := &tfjson.StateModule{}
+	modules := map[string]*tfjson.StateModule{"": root}
+	var module func(address string) *tfjson.StateModule
+	module = func(address string) *tfjson.StateModule {
+		if m, ok := modules[address]; ok {
+			return m
+		}
+		parent := root
+		if idx := strings.LastIndex(address, ".module."); idx != -1 {
+			parent = module(address[:idx])
+		}
+		m := &tfjson.StateModule{Address: address}
+		parent.ChildModules = append(parent.ChildModules, m)
+		modules[address] = m
+		return m
+	}
+
+	for _, r := range f.State.Resources {
+		m := module(r.Module)
+		for _, instance := range r.Instances {
+			m.Resources = append(m.Resources, &tfjson.StateResource{
+				Address:         instance.ID(r),
+				Mode:            tfjson.ResourceMode(r.Mode),
+				Type:            r.Type,
+				Name:            r.Name,
+				Index:           instance.IndexKey,
+				ProviderName:    r.Provider,
+				AttributeValues: map[string]interface{}{"id": instance.Attributes.ID},
+			})
+		}
+	}
+
+	return &tfjson.State{FormatVersion: "1.0", Values: &tfjson.StateValues{RootModule: root}}, nil
+}
+
+func (f *FakeTerraform) Move(ctx context.Context, source, destination string) error {
+	if err := f.call(fmt.Sprintf("state mv %s %s", source, destination)); err != nil {
+		return err
+	}
+
+	if f.find(destination) != nil {
+		return fmt.Errorf("invalid target address: destination %s already exists", destination)
+	}
+	instance, ok := f.take(source)
+	if !ok {
+		return fmt.Errorf("invalid source address: no matching objects found for %s", source)
+	}
+
+	resourceAddress, key, err := splitInstanceAddress(destination)
+	if err != nil {
+		return err
+	}
+	instance.IndexKey = key
+	for i, r := range f.State.Resources {
+		if r.ID() == resourceAddress {
+			f.State.Resources[i].Instances = append(r.Instances, instance)
+			return nil
+		}
+	}
+
+	source, _, _ = splitInstanceAddress(source)
+	for _, r := range f.State.Resources {
+		if r.ID() == source {
+			r.Module, r.Type, r.Name = splitResourceAddress(resourceAddress)
+			r.Instances = []Instance{instance}
+			f.State.Resources = append(f.State.Resources, r)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid source address: no matching objects found for %s", source)
+}
+
+func (f *FakeTerraform) Push(ctx context.Context, path string, force bool) error {
+	command := fmt.Sprintf("state push %s", path)
+	if force {
+		command = fmt.Sprintf("state push -force %s", path)
+	}
+	if err := f.call(command); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var tfstate TerraformState
+	if err := tfstate.parseState(data); err != nil {
+		return err
+	}
+
+	// like Terraform, only a successor of the current state is accepted unless forced
+	if !force && f.State.Lineage != "" && tfstate.Lineage != f.State.Lineage {
+		return fmt.Errorf("cannot import state with lineage %q over unrelated state with lineage %q", tfstate.Lineage, f.State.Lineage)
+	}
+	if !force && (tfstate.Serial < f.State.Serial || tfstate.Serial == f.State.Serial && !reflect.DeepEqual(tfstate.Resources, f.State.Resources)) {
+		return fmt.Errorf("cannot overwrite existing state with serial %d with a different state that has serial %d", f.State.Serial, tfstate.Serial)
+	}
+	f.State = tfstate
+	return nil
+}
+
+func (f *FakeTerraform) Apply(ctx context.Context, targets []string) error {
+	// the fake has no configuration, so nothing is created in the state
+	return f.call("apply -target=" + strings.Join(targets, " -target="))
+}
+
+func (f *FakeTerraform) PlanRefreshOnly(ctx context.Context) (bool, error) {
+	if err := f.call("plan -refresh-only"); err != nil {
+		return false, err
+	}
+
+	// the fake state is the truth, so there is never a difference
+	return false, nil
+}
+
+func (f *FakeTerraform) find(address string) *Instance {
+	for _, r := range f.State.Resources {
+		for i, instance := range r.Instances {
+			if instance.ID(r) == address {
+				return &r.Instances[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (f *FakeTerraform) take(address string) (Instance, bool) {
+	for i, r := range f.State.Resources {
+		for j, instance := range r.Instances {
+			if instance.ID(r) == address {
+				f.State.Resources[i].Instances = append(r.Instances[:j:j], r.Instances[j+1:]...)
+				return instance, true
+			}
+		}
+	}
+	return Instance{}, false
+}
+
+// templateEscapes undoes the escaping of template sequences in quoted instance keys, see hclQuote.
+var templateEscapes = strings.NewReplacer("$${", "${", "%%{", "%{")
+
+// splitInstanceAddress splits the index key off a resource instance address, i.e. `azurerm_subnet.example["a"]`.
+// Numeric keys are returned as float64, like they are when the state is parsed.
+func splitInstanceAddress(address string) (string, interface{}, error) {
+	if !strings.HasSuffix(address, "]") {
+		return address, nil, nil
+	}
+
+	if strings.HasSuffix(address, "\"]") {
+		idx := strings.LastIndex(address, "[\"")
+		if idx == -1 {
+			return "", nil, fmt.Errorf("invalid instance key in address: %s", address)
+		}
+		key, err := strconv.Unquote(templateEscapes.Replace(address[idx+1 : len(address)-1]))
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid instance key in address: %s", address)
+		}
+		return address[:idx], key, nil
+	}
+
+	idx := strings.LastIndex(address, "[")
+	if idx == -1 {
+		return "", nil, fmt.Errorf("invalid instance key in address: %s", address)
+	}
+	key, err := strconv.ParseFloat(address[idx+1:len(address)-1], 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid instance key in address: %s", address)
+	}
+	return address[:idx], key, nil
+}
+
+// splitResourceAddress splits a resource address without index key in its module, type and name.
+func splitResourceAddress(address string) (module, resourceType, name string) {
+	idx := strings.LastIndex(address, ".")
+	if idx == -1 {
+		return "", "", address
+	}
+	name = address[idx+1:]
+	address = address[:idx]
+
+	idx = strings.LastIndex(address, ".")
+	resourceType = address[idx+1:]
+	if idx != -1 {
+		module = address[:idx]
+	}
+	return module, resourceType, name
+}
@@ -0,0 +1,157 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func fakeState() TerraformState {
+	return TerraformState{
+		Resources: []Resource{
+			{
+				Provider: "provider[\"registry.terraform.io/hashicorp/azurerm\"]",
+				Module:   "module.storage",
+				Type:     "azurerm_storage_account",
+				Name:     "example",
+				Mode:     "managed",
+				Instances: []Instance{
+					{
+						IndexKey: "eu",
+						Attributes: Attributes{
+							ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount1",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestFakeTerraformReimport(t *testing.T) {
+	ctx := context.Background()
+	tf := NewFakeTerraform(fakeState())
+	address := "module.storage.azurerm_storage_account.example[\"eu\"]"
+	newID := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+
+	if err := tf.Remove(ctx, address); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tf.Import(ctx, address, newID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tfstate, _ := tf.Pull(ctx)
+	got := tfstate.Resources[0].Instances
+	wanted := []Instance{{IndexKey: "eu", Attributes: Attributes{ID: newID}}}
+	if !reflect.DeepEqual(got, wanted) {
+		t.Errorf("got %v wanted %v", got, wanted)
+	}
+
+	wantedCalls := []string{
+		"state rm " + address,
+		"import " + address + " " + newID,
+		"state pull",
+	}
+	if !reflect.DeepEqual(tf.Calls, wantedCalls) {
+		t.Errorf("got %v wanted %v", tf.Calls, wantedCalls)
+	}
+}
+
+func TestFakeTerraformErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Import already managed", func(t *testing.T) {
+		tf := NewFakeTerraform(fakeState())
+		err := tf.Import(ctx, "module.storage.azurerm_storage_account.example[\"eu\"]", "/some/id")
+		if err == nil {
+			t.Errorf("got no error, wanted one")
+		}
+	})
+
+	t.Run("Import outside configuration", func(t *testing.T) {
+		tf := NewFakeTerraform(fakeState())
+		err := tf.Import(ctx, "azurerm_storage_account.unknown", "/some/id")
+		if err == nil {
+			t.Errorf("got no error, wanted one")
+		}
+	})
+
+	t.Run("Injected error", func(t *testing.T) {
+		tf := NewFakeTerraform(fakeState())
+		wantedError := fmt.Errorf("state locked")
+		tf.Errors["state pull"] = wantedError
+		_, gotError := tf.Pull(ctx)
+		if gotError != wantedError {
+			t.Errorf("got %v wanted %v", gotError, wantedError)
+		}
+	})
+}
+
+func TestFakeTerraformMove(t *testing.T) {
+	ctx := context.Background()
+	tf := NewFakeTerraform(fakeState())
+
+	err := tf.Move(ctx, "module.storage.azurerm_storage_account.example[\"eu\"]", "module.data.azurerm_storage_account.moved[0]")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tfstate, _ := tf.Pull(ctx)
+	got := tfstate.Resources[1].Instances[0].ID(tfstate.Resources[1])
+	wanted := "module.data.azurerm_storage_account.moved[0]"
+	if got != wanted {
+		t.Errorf("got %s wanted %s", got, wanted)
+	}
+	if len(tfstate.Resources[0].Instances) != 0 {
+		t.Errorf("got %d instances at the source wanted 0", len(tfstate.Resources[0].Instances))
+	}
+}
+
+func TestFakeTerraformShow(t *testing.T) {
+	tf := NewFakeTerraform(fakeState())
+	tf.State.Resources[0].Module = "module.storage[0].module.accounts"
+
+	show, err := tf.Show(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parent := show.Values.RootModule.ChildModules[0]
+	child := parent.ChildModules[0]
+	if parent.Address != "module.storage[0]" || child.Address != "module.storage[0].module.accounts" {
+		t.Errorf("got modules %s and %s wanted module.storage[0] and module.storage[0].module.accounts", parent.Address, child.Address)
+	}
+	got := child.Resources[0].Address
+	wanted := "module.storage[0].module.accounts.azurerm_storage_account.example[\"eu\"]"
+	if got != wanted {
+		t.Errorf("got %s wanted %s", got, wanted)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	ctx := context.Background()
+	tf := NewFakeTerraform(fakeState())
+	dir := t.TempDir()
+
+	path, err := Backup(ctx, tf, dir, time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wanted := filepath.Join(dir, "terraform.tfstate.20230405T060708Z.aztfmove.backup")
+	if path != wanted {
+		t.Errorf("got %s wanted %s", path, wanted)
+	}
+
+	// the backup is restored by pushing it
+	tf.Remove(ctx, "module.storage.azurerm_storage_account.example[\"eu\"]")
+	if err := tf.Push(ctx, path, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(tf.State.Resources, fakeState().Resources) {
+		t.Errorf("got %v wanted %v", tf.State.Resources, fakeState().Resources)
+	}
+}
@@ -2,43 +2,128 @@ package state
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/aristosvo/aztfmove/azureid"
 )
 
 type ResourceInstanceSummary struct {
-	AzureID       string
-	TerraformID   string
-	FutureAzureID string
-	Type          string
+	AzureID        string   `json:"azure_id"`
+	TerraformID    string   `json:"terraform_id"`
+	FutureAzureID  string   `json:"future_azure_id"`
+	Type           string   `json:"type"`
+	SubscriptionID string   `json:"subscription_id,omitempty"`
+	ResourceGroup  string   `json:"resource_group,omitempty"`
+	Dependencies   []string `json:"dependencies,omitempty"`
+	// ARMType and APIVersion are taken from the `type` attribute of azapi resources, like
+	// `Microsoft.Storage/storageAccounts@2023-01-01`.
+	ARMType    string `json:"arm_type,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
+	// Unmanaged resources are not in the Terraform state, these are only moved in Azure, see Unmanaged.
+	Unmanaged bool `json:"unmanaged,omitempty"`
 }
 
 type ResourcesInstanceSummary []ResourceInstanceSummary
 
-func (ris ResourcesInstanceSummary) NotSupported() []string {
+// Category describes how a resource is handled in a move.
+type Category string
+
+const (
+	// Movable resources are moved in Azure and corrected in Terraform.
+	Movable Category = "movable"
+	// OnlyMovedInTF resources are moved along with their parent in Azure, these are only corrected in Terraform.
+	OnlyMovedInTF Category = "only-moved-in-terraform"
+	// Blocking resources block the move of other resources, these are deleted and recreated by Terraform.
+	Blocking Category = "blocking"
+	// NotSupported resources can't be moved in Azure.
+	NotSupported Category = "not-supported"
+	// NoMovementNeeded resources don't refer to a resource group, these stay as they are.
+	NoMovementNeeded Category = "no-movement-needed"
+)
+
+// Category returns how the resource is handled in a move. Types in the type config have a fixed category, as do azapi
+// resources of the same ARM type, other types are classified by the move support of their ARM type.
+func (r ResourceInstanceSummary) Category(config *Config) Category {
+	var category Category
+	if info, ok := config.LookupType(r.Type); ok {
+		category = info.Category
+	} else if info, ok := config.lookupARMType(r.ARMType); ok {
+		category = info.Category
+	} else {
+		category, _ = r.classify(config)
+	}
+
+	// extension resources, like a role assignment on a storage account, are never moved in Azure themselves
+	if category == Movable && isExtension(r.AzureID) {
+		return NotSupported
+	}
+	return category
+}
+
+// Unclassified returns the resources which are neither in the type config nor in the move support matrix. These are
+// considered movable, only the validation by Azure tells whether these can be moved.
+func (ris ResourcesInstanceSummary) Unclassified(config *Config) []string {
 	var IDs []string
 	for _, r := range ris {
-		if contains(resourcesNotSupportedInAzure, r.Type) {
+		if _, ok := config.LookupType(r.Type); ok {
+			continue
+		}
+		if _, ok := config.lookupARMType(r.ARMType); ok {
+			continue
+		}
+		if _, ok := r.classify(config); !ok {
 			IDs = append(IDs, r.TerraformID)
 		}
 	}
 	return IDs
 }
 
-func (ris ResourcesInstanceSummary) NoMovementNeeded() []string {
+// Importable reports whether the resource can be imported in the Terraform state, resources which can't are only
+// removed and created again by Terraform, like the updates of `azapi_update_resource`.
+func (r ResourceInstanceSummary) Importable(config *Config) bool {
+	info, _ := config.LookupType(r.Type)
+	return !info.NoImport
+}
+
+// DeleteAPIVersion returns the API version to delete a blocking resource with, the one of the `type` attribute of
+// azapi resources or otherwise the one of its type.
+func (r ResourceInstanceSummary) DeleteAPIVersion(config *Config) string {
+	if r.APIVersion != "" {
+		return r.APIVersion
+	}
+	if info, ok := config.LookupType(r.Type); ok {
+		return info.DeleteAPIVersion
+	}
+	info, _ := config.lookupARMType(r.ARMType)
+	return info.DeleteAPIVersion
+}
+
+func (ris ResourcesInstanceSummary) NotSupported(config *Config) []string {
 	var IDs []string
 	for _, r := range ris {
-		if contains(resourcesNotNeedingMovement, r.Type) {
+		if r.Category(config) == NotSupported {
 			IDs = append(IDs, r.TerraformID)
 		}
 	}
 	return IDs
 }
 
-func (ris ResourcesInstanceSummary) BlockingMovement() ([]string, []string) {
+func (ris ResourcesInstanceSummary) NoMovementNeeded(config *Config) []string {
+	var IDs []string
+	for _, r := range ris {
+		if r.Category(config) == NoMovementNeeded {
+			IDs = append(IDs, r.TerraformID)
+		}
+	}
+	return IDs
+}
+
+func (ris ResourcesInstanceSummary) BlockingMovement(config *Config) ([]string, []string) {
 	var tfIDs []string
 	var azureIDs []string
 	for _, r := range ris {
-		if contains(resourcesBlockingMovement, r.Type) {
+		if r.Category(config) == Blocking {
 			tfIDs = append(tfIDs, r.TerraformID)
 			azureIDs = append(azureIDs, r.AzureID)
 		}
@@ -46,153 +131,244 @@ func (ris ResourcesInstanceSummary) BlockingMovement() ([]string, []string) {
 	return tfIDs, azureIDs
 }
 
-func (ris ResourcesInstanceSummary) MovableOnAzure() []string {
+func (ris ResourcesInstanceSummary) MovableOnAzure(config *Config) []string {
 	var IDs []string
 	for _, r := range ris {
-		if !contains(resourcesNotSupportedInAzure, r.Type) && !contains(resourcesOnlyMovedInTF, r.Type) && !contains(resourcesBlockingMovement, r.Type) && !contains(resourcesNotNeedingMovement, r.Type) {
+		if r.movableOnAzure(config) {
 			IDs = append(IDs, r.AzureID)
 		}
 	}
 	return IDs
 }
 
-func (ris ResourcesInstanceSummary) ToCorrectInTFState() map[string]string {
-	IDs := make(map[string]string)
+func (r ResourceInstanceSummary) movableOnAzure(config *Config) bool {
+	return r.Category(config) == Movable
+}
+
+// MoveGroup is a set of resources which is moved in Azure with a single request, as every request is limited to one source resource group.
+type MoveGroup struct {
+	SubscriptionID string
+	ResourceGroup  string
+	AzureIDs       []string
+}
+
+// MoveGroups groups the resources movable on Azure by source subscription and resource group, ignoring case. Groups are ordered by the Terraform
+// dependencies between their resources, so resources are moved after the resources they depend on.
+func (ris ResourcesInstanceSummary) MoveGroups(config *Config) []MoveGroup {
+	groups := map[string]*MoveGroup{}
+	groupsOf := map[string][]string{}
 	for _, r := range ris {
-		if !contains(resourcesNotSupportedInAzure, r.Type) && !contains(resourcesBlockingMovement, r.Type) && !contains(resourcesNotNeedingMovement, r.Type) {
-			IDs[r.TerraformID] = r.FutureAzureID
+		if !r.movableOnAzure(config) {
+			continue
+		}
+		key := strings.ToLower(r.SubscriptionID + "/" + r.ResourceGroup)
+		if _, ok := groups[key]; !ok {
+			groups[key] = &MoveGroup{SubscriptionID: r.SubscriptionID, ResourceGroup: r.ResourceGroup}
 		}
+		groups[key].AzureIDs = append(groups[key].AzureIDs, r.AzureID)
+
+		resourceAddress, _, _ := splitInstanceAddress(r.TerraformID)
+		groupsOf[resourceAddress] = append(groupsOf[resourceAddress], key)
 	}
-	return IDs
-}
 
-var resourcesOnlyMovedInTF = []string{
-	"azurerm_app_service_slot",
-	"azurerm_app_service_slot_virtual_network_swift_connection",
-	"azurerm_key_vault_access_policy",
-	"azurerm_key_vault_secret",
-	"azurerm_mssql_database",
-	"azurerm_mssql_database_extended_auditing_policy",
-	"azurerm_mysql_firewall_rule",
-	"azurerm_sql_firewall_rule",
-	"azurerm_storage_container",
-	"azurerm_storage_share",
-	"azurerm_subnet",
-	"azurerm_subnet_network_security_group_association",
-}
+	dependsOn := map[string]map[string]bool{}
+	for _, r := range ris {
+		if !r.movableOnAzure(config) {
+			continue
+		}
+		key := strings.ToLower(r.SubscriptionID + "/" + r.ResourceGroup)
+		for _, dependency := range r.Dependencies {
+			for _, other := range groupsOf[dependency] {
+				if other == key {
+					continue
+				}
+				if dependsOn[key] == nil {
+					dependsOn[key] = map[string]bool{}
+				}
+				dependsOn[key][other] = true
+			}
+		}
+	}
 
-var resourcesBlockingMovement = []string{
-	"azurerm_app_service_virtual_network_swift_connection",
-	"azurerm_app_service_slot_virtual_network_swift_connection",
-}
+	var remaining []string
+	for key := range groups {
+		remaining = append(remaining, key)
+	}
+	sort.Strings(remaining)
 
-var resourcesNotSupportedInAzure = []string{
-	"azurerm_client_config",
-	"azurerm_kubernetes_cluster",
-	"azurerm_monitor_diagnostic_setting",
-	"azurerm_monitor_metric_alert",
-	"azurerm_resource_group",
-}
+	var ordered []MoveGroup
+	moved := map[string]bool{}
+	for len(remaining) > 0 {
+		next := -1
+		for i, key := range remaining {
+			ready := true
+			for dependency := range dependsOn[key] {
+				if !moved[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		// circular dependencies between resource groups can't be ordered, these are moved alphabetically
+		if next == -1 {
+			next = 0
+		}
 
-var resourcesNotNeedingMovement = []string{
-	"azurerm_storage_share_file", // lacks any reference to subscription/resource group and is a child resource, so no need to convert and/or move
+		key := remaining[next]
+		ordered = append(ordered, *groups[key])
+		moved[key] = true
+		remaining = append(remaining[:next], remaining[next+1:]...)
+	}
+	return ordered
 }
 
-func (tfstate TerraformState) Filter(resourceFilter, moduleFilter, resourceGroupFilter, sourceSubscriptionID, targetResourceGroup, targetSubscriptionID string) (resourceInstances ResourcesInstanceSummary, sourceResourceGroup string, err error) {
-	resourceGroup := resourceGroupFilter
-	for _, r := range tfstate.Resources {
-		if !strings.Contains(r.Provider, "provider[\"registry.terraform.io/hashicorp/azurerm\"]") || r.Mode != "managed" {
-			continue
+func (ris ResourcesInstanceSummary) ToCorrectInTFState(config *Config) map[string]string {
+	IDs := make(map[string]string)
+	for _, r := range ris {
+		if category := r.Category(config); (category == Movable || category == OnlyMovedInTF) && !r.Unmanaged {
+			IDs[r.TerraformID] = r.FutureAzureID
 		}
+	}
+	return IDs
+}
 
-		// first filter: resource
-		if (resourceFilter != "" && resourceFilter != "*") && r.ID() != resourceFilter {
-			continue
+// ToImport returns the resources to correct in the Terraform state which can be imported again, see Importable.
+func (ris ResourcesInstanceSummary) ToImport(config *Config) map[string]string {
+	IDs := ris.ToCorrectInTFState(config)
+	for _, r := range ris {
+		if !r.Importable(config) {
+			delete(IDs, r.TerraformID)
 		}
+	}
+	return IDs
+}
 
-		// second filter: module
-		if (moduleFilter != "" && moduleFilter != "*") && r.Module != moduleFilter {
-			continue
-		}
+// Filter selects the resource instances to move. The selection can span multiple source resource groups and, when
+// sourceSubscriptionFilter is "*", multiple source subscriptions, see MoveGroups.
+func (tfstate TerraformState) Filter(config *Config, selection Selection, resourceGroupFilter, sourceSubscriptionFilter, targetResourceGroup, targetSubscriptionID string) (resourceInstances ResourcesInstanceSummary, err error) {
+	targets, excludes, err := selection.compile()
+	if err != nil {
+		return nil, err
+	}
 
-		// third filter: resourcesNotNeedingMovement
-		if contains(resourcesNotNeedingMovement, r.Type) {
-			for _, instance := range r.Instances {
-				summary := ResourceInstanceSummary{
-					AzureID:       instance.Attributes.ID,
-					FutureAzureID: instance.Attributes.ID,
-					TerraformID:   instance.ID(r),
-					Type:          r.Type,
-				}
-				resourceInstances = append(resourceInstances, summary)
-			}
+	for _, r := range tfstate.Resources {
+		if !r.isAccepted(config) {
 			continue
 		}
 
+		// first filter: addresses of the instances, see Selection
+		var selected []Instance
 		for _, instance := range r.Instances {
-			if instance.SubscriptionID() == "" {
-				err = fmt.Errorf("subscription ID is not found for %s. Please file a PR on https://github.com/aristosvo/aztfmove and mention this ID: %s", instance.ID(r), instance.ID(r))
-				return nil, "", err
+			ok, err := selection.selects(targets, excludes, r, instance)
+			if err != nil {
+				return nil, err
 			}
-
-			// Only one subscription is supported at the same time
-			if instance.SubscriptionID() != sourceSubscriptionID {
-				err = fmt.Errorf("resource instance `%s` has a different subscription specified, unable to start moving. Resource instance subscription ID: %s, specified subscription ID: %s", instance.ID(r), strings.Split(instance.Attributes.ID, "/")[2], sourceSubscriptionID)
-				return nil, "", err
+			if ok {
+				selected = append(selected, instance)
 			}
+		}
 
-			instanceResourceGroup := instance.ResourceGroup()
-			if instanceResourceGroup == "" && !contains(resourcesNotSupportedInAzure, r.Type) && !contains(resourcesBlockingMovement, r.Type) {
-				err = fmt.Errorf("resource group is not found for %s. Please file a PR on https://github.com/aristosvo/aztfmove and mention this ID: %s", instance.ID(r), instance.ID(r))
-				return nil, "", err
+		for _, instance := range selected {
+			// second filter: types not needing movement
+			if unmoved := unmovedSummary(r, instance); unmoved.Category(config) == NoMovementNeeded {
+				resourceInstances = append(resourceInstances, unmoved)
+				continue
 			}
 
-			// thirth filter: resource group
-			if resourceGroupFilter != "*" && instanceResourceGroup != resourceGroupFilter {
+			// third filter: resource group
+			if resourceGroupFilter != "*" && !strings.EqualFold(instance.ResourceGroup(), resourceGroupFilter) {
 				continue
 			}
 
-			// Only one resource group is supported at the same time
-			if resourceGroup == "*" && !contains(resourcesNotSupportedInAzure, r.Type) && !contains(resourcesBlockingMovement, r.Type) {
-				resourceGroup = instanceResourceGroup
-			} else if resourceGroup != instanceResourceGroup && !contains(resourcesNotSupportedInAzure, r.Type) && !contains(resourcesBlockingMovement, r.Type) {
-
-				err = fmt.Errorf("multiple resource groups found within your selection, unable to start moving. Resource groups found: [%s, %s]", resourceGroup, instanceResourceGroup)
-				return nil, "", err
+			summary, err := instanceSummary(config, r, instance, sourceSubscriptionFilter, targetResourceGroup, targetSubscriptionID)
+			if err != nil {
+				return nil, err
 			}
-
-			if instance.SubscriptionID() == targetSubscriptionID && instanceResourceGroup == targetResourceGroup && !contains(resourcesNotSupportedInAzure, r.Type) && !contains(resourcesBlockingMovement, r.Type) {
+			if inTarget(config, summary, targetResourceGroup, targetSubscriptionID) {
 				err = fmt.Errorf("the selected resource %s is already in the target resource group", instance.ID(r))
-				return nil, "", err
-			}
-
-			// Prepare formatting of ID after movement. Maybe this could be extracted from the movement response?
-			// IDs which are formatted like /subscriptions/*/resourceGroups/* are considered sensitive for movement, IDs like https://example.blob.core.windows.net/container not
-			resourceGroupId := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", sourceSubscriptionID, resourceGroup)
-			targetResourceGroupId := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", targetSubscriptionID, targetResourceGroup)
-			futureAzureId := instance.Attributes.ID
-			if strings.HasPrefix(instance.Attributes.ID, resourceGroupId) {
-				futureAzureId = strings.Replace(instance.Attributes.ID, resourceGroupId, targetResourceGroupId, 1)
-			}
-
-			summary := ResourceInstanceSummary{
-				AzureID:       instance.Attributes.ID,
-				FutureAzureID: futureAzureId,
-				TerraformID:   instance.ID(r),
-				Type:          r.Type,
+				return nil, err
 			}
 			resourceInstances = append(resourceInstances, summary)
 		}
 	}
-	return resourceInstances, resourceGroup, nil
+	return resourceInstances, nil
 }
 
-func contains(s []string, str string) bool {
-	for _, v := range s {
-		if v == str {
-			return true
-		}
+// unmovedSummary summarizes a selected instance of a type which doesn't need movement.
+func unmovedSummary(r Resource, instance Instance) ResourceInstanceSummary {
+	armType, apiVersion := instance.azapiType(r)
+	return ResourceInstanceSummary{
+		AzureID:       instance.Attributes.ID,
+		FutureAzureID: instance.Attributes.ID,
+		TerraformID:   instance.ID(r),
+		Type:          r.Type,
+		Dependencies:  instance.Dependencies,
+		ARMType:       armType,
+		APIVersion:    apiVersion,
+	}
+}
+
+// instanceSummary summarizes a selected instance which needs movement, with its ID after the move.
+func instanceSummary(config *Config, r Resource, instance Instance, sourceSubscriptionFilter, targetResourceGroup, targetSubscriptionID string) (ResourceInstanceSummary, error) {
+	instanceSubscriptionID := instance.SubscriptionID()
+	if instanceSubscriptionID == "" {
+		instanceSubscriptionID = r.providerSubscriptionID(config)
+	}
+	instanceResourceGroup := instance.ResourceGroup()
+
+	// Subscription and resource group are only required for the selected instances
+	if instanceSubscriptionID == "" {
+		return ResourceInstanceSummary{}, fmt.Errorf("subscription ID is not found for %s. Set the subscription of its provider with -provider-subscription or file a PR on https://github.com/aristosvo/aztfmove and mention this ID: %s", instance.ID(r), instance.ID(r))
 	}
 
-	return false
+	// Multiple subscriptions are only supported when explicitly asked for
+	if sourceSubscriptionFilter != "*" && !strings.EqualFold(instanceSubscriptionID, sourceSubscriptionFilter) {
+		return ResourceInstanceSummary{}, fmt.Errorf("resource instance `%s` has a different subscription specified, unable to start moving. Resource instance subscription ID: %s, specified subscription ID: %s", instance.ID(r), instanceSubscriptionID, sourceSubscriptionFilter)
+	}
+
+	// Prepare formatting of ID after movement. Maybe this could be extracted from the movement response?
+	// IDs which are formatted like /subscriptions/*/resourceGroups/* are considered sensitive for movement, IDs like https://example.blob.core.windows.net/container not
+	futureAzureId := instance.Attributes.ID
+	if instanceResourceGroup != "" && !onResourceGroup(instance.Attributes.ID) {
+		futureAzureId, _ = azureid.Rescope(instance.Attributes.ID, azureid.ResourceGroupID(instanceSubscriptionID, instanceResourceGroup), azureid.ResourceGroupID(targetSubscriptionID, targetResourceGroup))
+	}
+
+	armType, apiVersion := instance.azapiType(r)
+	summary := ResourceInstanceSummary{
+		AzureID:        instance.Attributes.ID,
+		FutureAzureID:  futureAzureId,
+		TerraformID:    instance.ID(r),
+		Type:           r.Type,
+		SubscriptionID: instanceSubscriptionID,
+		ResourceGroup:  instanceResourceGroup,
+		Dependencies:   instance.Dependencies,
+		ARMType:        armType,
+		APIVersion:     apiVersion,
+	}
+
+	if category := summary.Category(config); instanceResourceGroup == "" && category != NotSupported && category != Blocking {
+		return ResourceInstanceSummary{}, fmt.Errorf("resource group is not found for %s. Please file a PR on https://github.com/aristosvo/aztfmove and mention this ID: %s", instance.ID(r), instance.ID(r))
+	}
+	return summary, nil
+}
+
+// inTarget reports whether a resource which can be moved is in the target resource group already.
+func inTarget(config *Config, r ResourceInstanceSummary, targetResourceGroup, targetSubscriptionID string) bool {
+	category := r.Category(config)
+	return strings.EqualFold(r.SubscriptionID, targetSubscriptionID) && strings.EqualFold(r.ResourceGroup, targetResourceGroup) && category != NotSupported && category != Blocking
+}
+
+// onResourceGroup reports whether the ID is a management lock on a resource group, these locks stay on the resource
+// group when the resources in it are moved.
+func onResourceGroup(id string) bool {
+	scope, _, ok := azureid.LockScope(id)
+	if !ok {
+		return false
+	}
+	parsed, err := azureid.Parse(scope)
+	return err == nil && parsed.ResourceGroup != "" && parsed.Type() == ""
 }
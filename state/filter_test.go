@@ -1,12 +1,12 @@
 package state
 
 import (
-	"fmt"
 	"reflect"
 	"testing"
 )
 
 func TestFilter(t *testing.T) {
+	config := DefaultConfig()
 	state := TerraformState{
 		Resources: []Resource{
 			{
@@ -91,21 +91,38 @@ func TestFilter(t *testing.T) {
 	}
 
 	t.Run("No filter", func(t *testing.T) {
-		_, _, gotError := state.Filter("*", "*", "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000001")
-		wantedError := fmt.Errorf("multiple resource groups found within your selection, unable to start moving. Resource groups found: [myresourcegroup1, myresourcegroup]")
-		if gotError.Error() != wantedError.Error() {
-			t.Errorf("got %v wanted %v", gotError, wantedError)
+		gotSummary, gotError := state.Filter(config, Selection{}, "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000001")
+		if gotError != nil {
+			t.Fatalf("got %v wanted no error", gotError)
+		}
+		gotGroups := gotSummary.MoveGroups(config)
+		wantedGroups := []MoveGroup{
+			{
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				ResourceGroup:  "myresourcegroup",
+				AzureIDs:       []string{"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount2"},
+			},
+			{
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				ResourceGroup:  "myresourcegroup1",
+				AzureIDs:       []string{"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup1/providers/Microsoft.Storage/storageAccounts/storageaccount1"},
+			},
+		}
+		if !reflect.DeepEqual(gotGroups, wantedGroups) {
+			t.Errorf("got %v wanted %v", gotGroups, wantedGroups)
 		}
 	})
 
 	t.Run("Resource filter", func(t *testing.T) {
-		gotSummary, _, _ := state.Filter("module.storage.azurerm_storage_container.example_container_2", "*", "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000001")
+		gotSummary, _ := state.Filter(config, Selection{Resource: "module.storage.azurerm_storage_container.example_container_2"}, "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000001")
 		wantedSummary := ResourcesInstanceSummary{
 			{
-				AzureID:       "https://example.blob.core.windows.net/container_2",
-				TerraformID:   "module.storage.azurerm_storage_container.example_container_2",
-				FutureAzureID: "https://example.blob.core.windows.net/container_2",
-				Type:          "azurerm_storage_container",
+				AzureID:        "https://example.blob.core.windows.net/container_2",
+				TerraformID:    "module.storage.azurerm_storage_container.example_container_2",
+				FutureAzureID:  "https://example.blob.core.windows.net/container_2",
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				ResourceGroup:  "myresourcegroup",
+				Type:           "azurerm_storage_container",
 			},
 		}
 		if !reflect.DeepEqual(gotSummary, wantedSummary) {
@@ -114,19 +131,23 @@ func TestFilter(t *testing.T) {
 	})
 
 	t.Run("Module filter", func(t *testing.T) {
-		gotSummary, _, _ := state.Filter("*", "module.storage", "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000001")
+		gotSummary, _ := state.Filter(config, Selection{Module: "module.storage"}, "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000001")
 		wantedSummary := ResourcesInstanceSummary{
 			{
-				AzureID:       "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount2",
-				TerraformID:   "module.storage.azurerm_storage_account.example_storage_2",
-				FutureAzureID: "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/storageaccount2",
-				Type:          "azurerm_storage_account",
+				AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount2",
+				TerraformID:    "module.storage.azurerm_storage_account.example_storage_2",
+				FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/storageaccount2",
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				ResourceGroup:  "myresourcegroup",
+				Type:           "azurerm_storage_account",
 			},
 			{
-				AzureID:       "https://example.blob.core.windows.net/container_2",
-				TerraformID:   "module.storage.azurerm_storage_container.example_container_2",
-				FutureAzureID: "https://example.blob.core.windows.net/container_2",
-				Type:          "azurerm_storage_container",
+				AzureID:        "https://example.blob.core.windows.net/container_2",
+				TerraformID:    "module.storage.azurerm_storage_container.example_container_2",
+				FutureAzureID:  "https://example.blob.core.windows.net/container_2",
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				ResourceGroup:  "myresourcegroup",
+				Type:           "azurerm_storage_container",
 			},
 		}
 		if !reflect.DeepEqual(gotSummary, wantedSummary) {
@@ -135,19 +156,23 @@ func TestFilter(t *testing.T) {
 	})
 
 	t.Run("Module filter with diff resource group passing", func(t *testing.T) {
-		gotSummary, _, _ := state.Filter("*", "module.test", "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000001")
+		gotSummary, _ := state.Filter(config, Selection{Module: "module.test"}, "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000001")
 		wantedSummary := ResourcesInstanceSummary{
 			{
-				AzureID:       "https://example.blob.core.windows.net/container_1",
-				TerraformID:   "module.test.azurerm_storage_container.example_container_1",
-				FutureAzureID: "https://example.blob.core.windows.net/container_1",
-				Type:          "azurerm_storage_container",
+				AzureID:        "https://example.blob.core.windows.net/container_1",
+				TerraformID:    "module.test.azurerm_storage_container.example_container_1",
+				FutureAzureID:  "https://example.blob.core.windows.net/container_1",
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				ResourceGroup:  "myresourcegroup1",
+				Type:           "azurerm_storage_container",
 			},
 			{
-				AzureID:       "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg3",
-				TerraformID:   "module.test.azurerm_resource_group.rg3",
-				FutureAzureID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg3",
-				Type:          "azurerm_resource_group",
+				AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg3",
+				TerraformID:    "module.test.azurerm_resource_group.rg3",
+				FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/myresourcegroup2",
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				ResourceGroup:  "rg3",
+				Type:           "azurerm_resource_group",
 			},
 		}
 		if !reflect.DeepEqual(gotSummary, wantedSummary) {
@@ -156,13 +181,15 @@ func TestFilter(t *testing.T) {
 	})
 
 	t.Run("Resource Group filter", func(t *testing.T) {
-		gotSummary, _, _ := state.Filter("*", "*", "rg3", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000001")
+		gotSummary, _ := state.Filter(config, Selection{}, "rg3", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000001")
 		wantedSummary := ResourcesInstanceSummary{
 			{
-				AzureID:       "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg3",
-				TerraformID:   "module.test.azurerm_resource_group.rg3",
-				FutureAzureID: "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/myresourcegroup2",
-				Type:          "azurerm_resource_group",
+				AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg3",
+				TerraformID:    "module.test.azurerm_resource_group.rg3",
+				FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/myresourcegroup2",
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				ResourceGroup:  "rg3",
+				Type:           "azurerm_resource_group",
 			},
 		}
 		if !reflect.DeepEqual(gotSummary, wantedSummary) {
@@ -172,6 +199,7 @@ func TestFilter(t *testing.T) {
 }
 
 func TestNotSupported(t *testing.T) {
+	config := DefaultConfig()
 	summary := ResourcesInstanceSummary{
 		{
 			AzureID:       "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg3",
@@ -199,7 +227,7 @@ func TestNotSupported(t *testing.T) {
 		},
 	}
 	t.Run("Not Supported list", func(t *testing.T) {
-		got := summary.NotSupported()
+		got := summary.NotSupported(config)
 		wanted := []string{"module.test.azurerm_resource_group.rg3"}
 
 		if !reflect.DeepEqual(got, wanted) {
@@ -208,7 +236,7 @@ func TestNotSupported(t *testing.T) {
 	})
 
 	t.Run("Movable on Azure list", func(t *testing.T) {
-		got := summary.MovableOnAzure()
+		got := summary.MovableOnAzure(config)
 		wanted := []string{"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount2"}
 
 		if !reflect.DeepEqual(got, wanted) {
@@ -217,7 +245,7 @@ func TestNotSupported(t *testing.T) {
 	})
 
 	t.Run("To correct in TF map", func(t *testing.T) {
-		got := summary.ToCorrectInTFState()
+		got := summary.ToCorrectInTFState(config)
 		wanted := map[string]string{
 			"module.test.azurerm_storage_container.example_container_1":    "https://example.blob.core.windows.net/container_1",
 			"module.storage.azurerm_storage_account.example_storage_2":     "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/storageaccount2",
@@ -229,3 +257,169 @@ func TestNotSupported(t *testing.T) {
 		}
 	})
 }
+
+func TestMoveGroups(t *testing.T) {
+	config := DefaultConfig()
+	summary := ResourcesInstanceSummary{
+		{
+			AzureID:       "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/app-rg/providers/Microsoft.Web/sites/app",
+			TerraformID:   "azurerm_linux_web_app.app",
+			Type:          "azurerm_linux_web_app",
+			ResourceGroup: "app-rg",
+			Dependencies:  []string{"azurerm_service_plan.plan", "azurerm_storage_account.sa"},
+		},
+		{
+			AzureID:       "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/data-rg/providers/Microsoft.Storage/storageAccounts/sa",
+			TerraformID:   "azurerm_storage_account.sa",
+			Type:          "azurerm_storage_account",
+			ResourceGroup: "data-rg",
+			Dependencies:  []string{"azurerm_resource_group.data"},
+		},
+		{
+			AzureID:       "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/app-rg/providers/Microsoft.Web/serverFarms/plan",
+			TerraformID:   "azurerm_service_plan.plan",
+			Type:          "azurerm_service_plan",
+			ResourceGroup: "app-rg",
+		},
+		{
+			AzureID:       "https://sa.blob.core.windows.net/container",
+			TerraformID:   "azurerm_storage_container.container[\"logs\"]",
+			Type:          "azurerm_storage_container",
+			ResourceGroup: "data-rg",
+			Dependencies:  []string{"azurerm_storage_account.sa"},
+		},
+	}
+
+	got := summary.MoveGroups(config)
+	wanted := []MoveGroup{
+		{
+			ResourceGroup: "data-rg",
+			AzureIDs:      []string{"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/data-rg/providers/Microsoft.Storage/storageAccounts/sa"},
+		},
+		{
+			ResourceGroup: "app-rg",
+			AzureIDs: []string{
+				"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/app-rg/providers/Microsoft.Web/sites/app",
+				"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/app-rg/providers/Microsoft.Web/serverFarms/plan",
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, wanted) {
+		t.Errorf("got %v wanted %v", got, wanted)
+	}
+}
+
+func TestFilterSubscriptions(t *testing.T) {
+	config := DefaultConfig()
+	state := TerraformState{
+		Resources: []Resource{
+			{
+				Provider: "provider[\"registry.terraform.io/hashicorp/azurerm\"]",
+				Module:   "module.spoke",
+				Type:     "azurerm_storage_account",
+				Name:     "spoke",
+				Mode:     "managed",
+				Instances: []Instance{
+					{
+						Attributes: Attributes{
+							ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/spoke-rg/providers/Microsoft.Storage/storageAccounts/spoke",
+						},
+					},
+				},
+			},
+			{
+				Provider: "provider[\"registry.terraform.io/hashicorp/azurerm\"].hub",
+				Module:   "module.hub",
+				Type:     "azurerm_storage_account",
+				Name:     "hub",
+				Mode:     "managed",
+				Instances: []Instance{
+					{
+						Attributes: Attributes{
+							ID: "/subscriptions/00000000-0000-0000-0000-000000000009/resourceGroups/hub-rg/providers/Microsoft.Storage/storageAccounts/hub",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("Other subscription outside selection", func(t *testing.T) {
+		_, err := state.Filter(config, Selection{Module: "module.spoke"}, "*", "00000000-0000-0000-0000-000000000000", "target-rg", "00000000-0000-0000-0000-000000000000")
+		if err != nil {
+			t.Errorf("got %v wanted no error", err)
+		}
+
+		_, err = state.Filter(config, Selection{}, "spoke-rg", "00000000-0000-0000-0000-000000000000", "target-rg", "00000000-0000-0000-0000-000000000000")
+		if err != nil {
+			t.Errorf("got %v wanted no error", err)
+		}
+	})
+
+	t.Run("Other subscription inside selection", func(t *testing.T) {
+		_, err := state.Filter(config, Selection{}, "*", "00000000-0000-0000-0000-000000000000", "target-rg", "00000000-0000-0000-0000-000000000000")
+		if err == nil {
+			t.Errorf("got no error, wanted one")
+		}
+	})
+
+	t.Run("Multiple subscriptions", func(t *testing.T) {
+		summary, err := state.Filter(config, Selection{}, "*", "*", "target-rg", "00000000-0000-0000-0000-000000000001")
+		if err != nil {
+			t.Fatalf("got %v wanted no error", err)
+		}
+
+		got := summary.MoveGroups(config)
+		wanted := []MoveGroup{
+			{
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				ResourceGroup:  "spoke-rg",
+				AzureIDs:       []string{"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/spoke-rg/providers/Microsoft.Storage/storageAccounts/spoke"},
+			},
+			{
+				SubscriptionID: "00000000-0000-0000-0000-000000000009",
+				ResourceGroup:  "hub-rg",
+				AzureIDs:       []string{"/subscriptions/00000000-0000-0000-0000-000000000009/resourceGroups/hub-rg/providers/Microsoft.Storage/storageAccounts/hub"},
+			},
+		}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+
+		gotID := summary.ToCorrectInTFState(config)["module.hub.azurerm_storage_account.hub"]
+		wantedID := "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/target-rg/providers/Microsoft.Storage/storageAccounts/hub"
+		if gotID != wantedID {
+			t.Errorf("got %s wanted %s", gotID, wantedID)
+		}
+	})
+}
+
+func TestFilterLowerCaseResourceGroups(t *testing.T) {
+	config := DefaultConfig()
+	state := TerraformState{
+		Resources: []Resource{
+			{
+				Provider: "provider[\"registry.terraform.io/hashicorp/azurerm\"]",
+				Type:     "azurerm_storage_account",
+				Name:     "example",
+				Mode:     "managed",
+				Instances: []Instance{
+					{
+						Attributes: Attributes{
+							ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/MyResourceGroup/providers/Microsoft.Storage/storageAccounts/storageaccount1",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	got, err := state.Filter(config, Selection{}, "myresourcegroup", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000000")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wanted := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+	if len(got) != 1 || got[0].FutureAzureID != wanted || got[0].ResourceGroup != "MyResourceGroup" {
+		t.Errorf("got %v wanted FutureAzureID %s", got, wanted)
+	}
+}
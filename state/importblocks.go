@@ -0,0 +1,26 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// ImportBlocks returns Terraform configuration with an `import` block for every resource instance, ordered by address.
+// ids maps the address of every instance to the ID to import. Addresses are written as is, as these are formatted like
+// Terraform formats them, with quoted instance keys.
+func ImportBlocks(ids map[string]string) []byte {
+	var addresses []string
+	for address := range ids {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	var b bytes.Buffer
+	b.WriteString("# Generated by aztfmove. The resources below are moved in Azure and removed from the Terraform state,\n")
+	b.WriteString("# `terraform plan` and `terraform apply` import them again (Terraform v1.5 or later). Remove this file afterwards.\n")
+	for _, address := range addresses {
+		fmt.Fprintf(&b, "\nimport {\n  to = %s\n  id = %s\n}\n", address, hclQuote(ids[address]))
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,28 @@
+package state
+
+import "testing"
+
+func TestImportBlocks(t *testing.T) {
+	ids := map[string]string{
+		`module.storage["eu"].azurerm_storage_account.example["a"]`: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/storageaccount1",
+		"azurerm_app_service.app[0]":                                "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Web/sites/app",
+	}
+
+	got := string(ImportBlocks(ids))
+	wanted := `# Generated by aztfmove. The resources below are moved in Azure and removed from the Terraform state,
+# ` + "`terraform plan` and `terraform apply`" + ` import them again (Terraform v1.5 or later). Remove this file afterwards.
+
+import {
+  to = azurerm_app_service.app[0]
+  id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Web/sites/app"
+}
+
+import {
+  to = module.storage["eu"].azurerm_storage_account.example["a"]
+  id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+}
+`
+	if got != wanted {
+		t.Errorf("got %s wanted %s", got, wanted)
+	}
+}
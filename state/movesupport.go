@@ -0,0 +1,112 @@
+package state
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aristosvo/aztfmove/azureid"
+)
+
+// MoveSupport describes if resources of an ARM type can be moved to another resource group and to another subscription.
+type MoveSupport struct {
+	ResourceGroup bool `json:"resource_group"`
+	Subscription  bool `json:"subscription"`
+}
+
+type moveSupportMatrix struct {
+	Version  int                    `json:"version"`
+	Source   string                 `json:"source"`
+	ARMTypes map[string]MoveSupport `json:"arm_types"`
+}
+
+//go:embed movesupport.json
+var embeddedMoveSupport []byte
+
+// embeddedMoveSupportMatrix is keyed by lower case ARM type, as ARM types are case-insensitive. It's copied by
+// DefaultConfig and never changed.
+var embeddedMoveSupportMatrix = mustParseMoveSupport(embeddedMoveSupport)
+
+// LookupMoveSupport returns if resources of an ARM type, like `Microsoft.Storage/storageAccounts`, can be moved.
+func (c *Config) LookupMoveSupport(armType string) (MoveSupport, bool) {
+	support, ok := c.moveSupport[strings.ToLower(armType)]
+	return support, ok
+}
+
+func mustParseMoveSupport(data []byte) map[string]MoveSupport {
+	var matrix moveSupportMatrix
+	if err := json.Unmarshal(data, &matrix); err != nil {
+		panic(fmt.Sprintf("embedded movesupport.json cannot be parsed: %v", err))
+	}
+	if matrix.Version != 1 {
+		panic(fmt.Sprintf("embedded movesupport.json has unsupported version %d", matrix.Version))
+	}
+
+	support := map[string]MoveSupport{}
+	for armType, s := range matrix.ARMTypes {
+		support[strings.ToLower(armType)] = s
+	}
+	return support
+}
+
+// ARMType returns the ARM resource type of a resource ID, i.e. `Microsoft.Web/sites/slots`. For extension resources the
+// type of the extension is returned. IDs which aren't ARM resource IDs return "".
+func ARMType(id string) string {
+	parsed, err := azureid.Parse(id)
+	if err != nil {
+		return ""
+	}
+	return parsed.Type()
+}
+
+// isExtension reports whether the ID is of an extension resource, which is applied to another resource like a role
+// assignment on a storage account.
+func isExtension(id string) bool {
+	parsed, err := azureid.Parse(id)
+	return err == nil && parsed.Scope != nil
+}
+
+// classify derives the category of a resource from the move support of its ARM type. Child resources, which have no
+// move support of their own, are moved along with their parent. Extension resources are not supported. The ARM type of
+// azapi resources is their `type`.
+func (r ResourceInstanceSummary) classify(config *Config) (Category, bool) {
+	if isExtension(r.AzureID) {
+		return NotSupported, true
+	}
+	armType := r.ARMType
+	if armType == "" {
+		armType = ARMType(r.AzureID)
+	}
+	if armType == "" {
+		return Movable, false
+	}
+
+	support, ok := config.LookupMoveSupport(armType)
+	child := false
+	if !ok {
+		parts := strings.Split(armType, "/")
+		if len(parts) <= 2 {
+			return Movable, false
+		}
+		if support, ok = config.LookupMoveSupport(parts[0] + "/" + parts[1]); !ok {
+			return Movable, false
+		}
+		child = true
+	}
+
+	if !support.ResourceGroup || (r.crossSubscription() && !support.Subscription) {
+		return NotSupported, true
+	}
+	if child {
+		return OnlyMovedInTF, true
+	}
+	return Movable, true
+}
+
+// crossSubscription reports whether the resource is moved to another subscription.
+func (r ResourceInstanceSummary) crossSubscription() bool {
+	from, _, ok := azureid.Scope(r.AzureID)
+	to, _, futureOK := azureid.Scope(r.FutureAzureID)
+	return ok && futureOK && !strings.EqualFold(from, to)
+}
@@ -0,0 +1,103 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestARMType(t *testing.T) {
+	tests := map[string]string{
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app":                                                           "Microsoft.Web/sites",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Web/sites/app/slots/staging":                                             "Microsoft.Web/sites/slots",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.KeyVault/vaults/kv/providers/Microsoft.Insights/diagnosticSettings/logs": "Microsoft.Insights/diagnosticSettings",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/myresourcegroup/PROVIDERS/Microsoft.Network/virtualNetworks/vnet/subnets/subnet":                             "Microsoft.Network/virtualNetworks/subnets",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup":                                                                                             "",
+		"https://example.blob.core.windows.net/container": "",
+	}
+	for id, wanted := range tests {
+		if got := ARMType(id); got != wanted {
+			t.Errorf("got %s wanted %s for %s", got, wanted, id)
+		}
+	}
+}
+
+func TestCategory(t *testing.T) {
+	config := DefaultConfig()
+	path := filepath.Join(t.TempDir(), "types.json")
+	err := os.WriteFile(path, []byte(`{"version": 1, "types": {}, "arm_types": {"Microsoft.Example/things": {"resource_group": true, "subscription": false}}}`), 0600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := config.LoadTypeConfig(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	summary := func(resourceType, id, futureID string) ResourceInstanceSummary {
+		return ResourceInstanceSummary{TerraformID: resourceType + ".example", Type: resourceType, AzureID: id, FutureAzureID: futureID}
+	}
+	source := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/"
+	sameSubscription := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/"
+	otherSubscription := "/subscriptions/11111111-1111-1111-1111-111111111111/resourceGroups/myresourcegroup2/providers/"
+
+	ris := ResourcesInstanceSummary{
+		// in the type config
+		summary("azurerm_subnet", source+"Microsoft.Network/virtualNetworks/vnet/subnets/subnet", sameSubscription+"Microsoft.Network/virtualNetworks/vnet/subnets/subnet"),
+		// classified by the move support matrix
+		summary("azurerm_linux_function_app", source+"Microsoft.Web/sites/func", otherSubscription+"Microsoft.Web/sites/func"),
+		summary("azurerm_bastion_host", source+"Microsoft.Network/bastionHosts/bastion", sameSubscription+"Microsoft.Network/bastionHosts/bastion"),
+		summary("azurerm_network_security_rule", source+"Microsoft.Network/networkSecurityGroups/nsg/securityRules/rule", sameSubscription+"Microsoft.Network/networkSecurityGroups/nsg/securityRules/rule"),
+		summary("azurerm_example_thing", source+"Microsoft.Example/things/thing", sameSubscription+"Microsoft.Example/things/thing"),
+		summary("azurerm_example_thing", source+"Microsoft.Example/things/thing", otherSubscription+"Microsoft.Example/things/thing"),
+		summary("azurerm_example_assignment", source+"Microsoft.Storage/storageAccounts/sa/providers/Microsoft.Authorization/roleAssignments/assignment", sameSubscription+"Microsoft.Storage/storageAccounts/sa/providers/Microsoft.Authorization/roleAssignments/assignment"),
+		// unknown
+		summary("azurerm_unknown", source+"Microsoft.Unknown/things/thing", sameSubscription+"Microsoft.Unknown/things/thing"),
+	}
+
+	var got []Category
+	for _, r := range ris {
+		got = append(got, r.Category(config))
+	}
+	wanted := []Category{OnlyMovedInTF, Movable, NotSupported, OnlyMovedInTF, Movable, NotSupported, NotSupported, Movable}
+	if !reflect.DeepEqual(got, wanted) {
+		t.Errorf("got %v wanted %v", got, wanted)
+	}
+
+	unclassified := ris.Unclassified(config)
+	wantedUnclassified := []string{"azurerm_unknown.example"}
+	if !reflect.DeepEqual(unclassified, wantedUnclassified) {
+		t.Errorf("got %v wanted %v", unclassified, wantedUnclassified)
+	}
+}
+
+func TestMoveSupportCoverage(t *testing.T) {
+	config := DefaultConfig()
+	if len(config.moveSupport) < 400 {
+		t.Errorf("got %d ARM types wanted at least 400, the matrix should cover the published list", len(config.moveSupport))
+	}
+
+	namespaces := map[string]bool{}
+	for armType := range config.moveSupport {
+		namespaces[strings.Split(armType, "/")[0]] = true
+	}
+	for _, namespace := range []string{"microsoft.app", "microsoft.compute", "microsoft.dbforpostgresql", "microsoft.desktopvirtualization", "microsoft.insights", "microsoft.network", "microsoft.sql", "microsoft.web"} {
+		if !namespaces[namespace] {
+			t.Errorf("namespace %s is not in the matrix", namespace)
+		}
+	}
+
+	wanted := map[string]MoveSupport{
+		"Microsoft.DBforPostgreSQL/flexibleServers": {ResourceGroup: true, Subscription: true},
+		"Microsoft.ClassicStorage/storageAccounts":  {ResourceGroup: true, Subscription: false},
+		"Microsoft.NetApp/netAppAccounts":           {ResourceGroup: false, Subscription: false},
+		"Microsoft.Authorization/roleAssignments":   {ResourceGroup: false, Subscription: false},
+		"Microsoft.Insights/diagnosticSettings":     {ResourceGroup: false, Subscription: false},
+	}
+	for armType, support := range wanted {
+		if got, ok := config.LookupMoveSupport(armType); !ok || got != support {
+			t.Errorf("got %v wanted %v for %s", got, support, armType)
+		}
+	}
+}
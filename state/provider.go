@@ -0,0 +1,150 @@
+package state
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const defaultRegistry = "registry.terraform.io"
+
+// ProviderAddress is the provider configuration of a resource in the state, like
+// `module.hub.provider["registry.terraform.io/hashicorp/azurerm"].connectivity`.
+type ProviderAddress struct {
+	// Module is the module the provider is configured in, empty for the root module.
+	Module    string
+	Hostname  string
+	Namespace string
+	Type      string
+	Alias     string
+}
+
+// Source returns the fully qualified source of the provider, like `registry.terraform.io/hashicorp/azurerm`.
+func (p ProviderAddress) Source() string {
+	return p.Hostname + "/" + p.Namespace + "/" + p.Type
+}
+
+// Config returns the provider configuration as it's referred to in Terraform configuration, like `azurerm.hub`,
+// prefixed with the module it's configured in.
+func (p ProviderAddress) Config() string {
+	config := p.Type
+	if p.Alias != "" {
+		config += "." + p.Alias
+	}
+	if p.Module != "" {
+		config = p.Module + "." + config
+	}
+	return config
+}
+
+// ParseProvider parses the provider of a resource in the state, both `provider["registry.terraform.io/hashicorp/azurerm"]`
+// and the legacy `provider.azurerm` of Terraform 0.12, with an optional alias and module.
+func ParseProvider(s string) (ProviderAddress, error) {
+	parts := splitAddress(s)
+	for i, part := range parts {
+		var p ProviderAddress
+		var rest []string
+		switch {
+		case strings.HasPrefix(part, "provider[") && strings.HasSuffix(part, "]"):
+			source, err := strconv.Unquote(part[len("provider[") : len(part)-1])
+			if err != nil {
+				return ProviderAddress{}, fmt.Errorf("invalid provider address %s", s)
+			}
+			if p, err = parseSource(source); err != nil {
+				return ProviderAddress{}, fmt.Errorf("invalid provider address %s: %v", s, err)
+			}
+			rest = parts[i+1:]
+		case part == "provider" && i+1 < len(parts) && (i == 0 || parts[i-1] != "module"):
+			p = ProviderAddress{Hostname: defaultRegistry, Namespace: "hashicorp", Type: parts[i+1]}
+			rest = parts[i+2:]
+		default:
+			continue
+		}
+
+		switch len(rest) {
+		case 0:
+		case 1:
+			p.Alias = rest[0]
+		default:
+			return ProviderAddress{}, fmt.Errorf("invalid provider address %s", s)
+		}
+		p.Module = strings.Join(parts[:i], ".")
+		return p, nil
+	}
+	return ProviderAddress{}, fmt.Errorf("invalid provider address %s", s)
+}
+
+// parseSource parses a provider source like `hashicorp/azurerm`, the hostname defaults to the Terraform registry.
+func parseSource(source string) (ProviderAddress, error) {
+	parts := strings.Split(source, "/")
+	for _, part := range parts {
+		if part == "" {
+			return ProviderAddress{}, fmt.Errorf("invalid provider source %s", source)
+		}
+	}
+
+	switch len(parts) {
+	case 2:
+		return ProviderAddress{Hostname: defaultRegistry, Namespace: parts[0], Type: parts[1]}, nil
+	case 3:
+		return ProviderAddress{Hostname: parts[0], Namespace: parts[1], Type: parts[2]}, nil
+	}
+	return ProviderAddress{}, fmt.Errorf("invalid provider source %s", source)
+}
+
+// AddProviderSource accepts resources of another source of the azurerm or azapi provider, like a private registry
+// mirror.
+func (c *Config) AddProviderSource(source string) error {
+	p, err := parseSource(source)
+	if err != nil {
+		return err
+	}
+	c.providerSources = append(c.providerSources, p.Source())
+	return nil
+}
+
+// SetProviderSubscription sets the subscription of a provider configuration, like `azurerm.hub`. Instances of which
+// the subscription can't be derived from their attributes are in the subscription of their provider configuration.
+func (c *Config) SetProviderSubscription(providerConfig, subscriptionID string) {
+	c.providerSubscriptions[strings.ToLower(providerConfig)] = subscriptionID
+}
+
+// isAccepted reports whether the resource is managed by one of the accepted sources of the azurerm or azapi provider.
+func (r Resource) isAccepted(config *Config) bool {
+	if r.Mode != "managed" {
+		return false
+	}
+	p, err := ParseProvider(r.Provider)
+	if err != nil {
+		return false
+	}
+	for _, source := range config.providerSources {
+		if strings.EqualFold(p.Source(), source) {
+			return true
+		}
+	}
+	return false
+}
+
+// providerSubscriptionID returns the subscription set for the provider configuration of the resource, if any.
+func (r Resource) providerSubscriptionID(config *Config) string {
+	p, err := ParseProvider(r.Provider)
+	if err != nil {
+		return ""
+	}
+	return config.providerSubscriptions[strings.ToLower(p.Config())]
+}
+
+// IgnoredProviderSources returns the sources of the azurerm and azapi providers in the state which are not accepted,
+// these resources are ignored.
+func (tfstate TerraformState) IgnoredProviderSources(config *Config) []string {
+	var ignored []string
+	for _, r := range tfstate.Resources {
+		p, err := ParseProvider(r.Provider)
+		if err != nil || r.Mode != "managed" || (p.Type != "azurerm" && p.Type != "azapi") || r.isAccepted(config) || contains(ignored, p.Source()) {
+			continue
+		}
+		ignored = append(ignored, p.Source())
+	}
+	return ignored
+}
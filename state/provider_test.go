@@ -0,0 +1,108 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		want       ProviderAddress
+		config     string
+		shouldFail bool
+	}{
+		{
+			name:     "Terraform registry",
+			provider: "provider[\"registry.terraform.io/hashicorp/azurerm\"]",
+			want:     ProviderAddress{Hostname: "registry.terraform.io", Namespace: "hashicorp", Type: "azurerm"},
+			config:   "azurerm",
+		},
+		{
+			name:     "OpenTofu registry with alias",
+			provider: "provider[\"registry.opentofu.org/hashicorp/azurerm\"].hub",
+			want:     ProviderAddress{Hostname: "registry.opentofu.org", Namespace: "hashicorp", Type: "azurerm", Alias: "hub"},
+			config:   "azurerm.hub",
+		},
+		{
+			name:     "Module named provider",
+			provider: "module.provider.provider[\"terraform.example.com/hashicorp/azurerm\"].spoke",
+			want:     ProviderAddress{Module: "module.provider", Hostname: "terraform.example.com", Namespace: "hashicorp", Type: "azurerm", Alias: "spoke"},
+			config:   "module.provider.azurerm.spoke",
+		},
+		{
+			name:     "Terraform 0.12",
+			provider: "provider.azurerm.hub",
+			want:     ProviderAddress{Hostname: "registry.terraform.io", Namespace: "hashicorp", Type: "azurerm", Alias: "hub"},
+			config:   "azurerm.hub",
+		},
+		{
+			name:       "Invalid source",
+			provider:   "provider[\"azurerm\"]",
+			shouldFail: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProvider(tt.provider)
+			if (err != nil) != tt.shouldFail {
+				t.Fatalf("got error %v wanted failure %t", err, tt.shouldFail)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v wanted %v", got, tt.want)
+			}
+			if !tt.shouldFail && got.Config() != tt.config {
+				t.Errorf("got %v wanted %v", got.Config(), tt.config)
+			}
+		})
+	}
+}
+
+func TestProviderSources(t *testing.T) {
+	config := DefaultConfig()
+	state := TerraformState{
+		Resources: []Resource{
+			{
+				Provider: "provider[\"registry.opentofu.org/hashicorp/azurerm\"]",
+				Type:     "azurerm_storage_account",
+				Name:     "tofu",
+				Mode:     "managed",
+				Instances: []Instance{
+					{Attributes: Attributes{ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/tofu"}},
+				},
+			},
+			{
+				Provider: "provider[\"terraform.example.com/hashicorp/azurerm\"].hub",
+				Type:     "azurerm_kubernetes_cluster",
+				Name:     "mirror",
+				Mode:     "managed",
+				Instances: []Instance{
+					{Attributes: Attributes{ID: "https://mirror.hcp.westeurope.azmk8s.io"}},
+				},
+			},
+		},
+	}
+
+	if got, wanted := state.IgnoredProviderSources(config), []string{"terraform.example.com/hashicorp/azurerm"}; !reflect.DeepEqual(got, wanted) {
+		t.Errorf("got %v wanted %v", got, wanted)
+	}
+
+	if err := config.AddProviderSource("terraform.example.com/hashicorp/azurerm"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config.SetProviderSubscription("azurerm.Hub", "00000000-0000-0000-0000-000000000001")
+
+	summary, err := state.Filter(config, Selection{}, "*", "*", "myresourcegroup2", "00000000-0000-0000-0000-000000000000")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, r := range summary {
+		got = append(got, r.TerraformID+" "+r.SubscriptionID)
+	}
+	wanted := []string{"azurerm_storage_account.tofu 00000000-0000-0000-0000-000000000000", "azurerm_kubernetes_cluster.mirror 00000000-0000-0000-0000-000000000001"}
+	if !reflect.DeepEqual(got, wanted) {
+		t.Errorf("got %v wanted %v", got, wanted)
+	}
+}
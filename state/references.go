@@ -0,0 +1,165 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aristosvo/aztfmove/azureid"
+)
+
+// Reference is a string attribute of a resource instance which refers to a moved resource, like `subnet_id`, or to
+// the resource group it's moved from, like `resource_group_name` or the `parent_id` of azapi resources of a moved
+// instance.
+type Reference struct {
+	Address     string `json:"address"`
+	Attribute   string `json:"attribute"`
+	Value       string `json:"value"`
+	FutureValue string `json:"future_value"`
+	// Dangling is set for attributes of instances which are not selected, these refer to the old location after the
+	// move until the Terraform state is corrected.
+	Dangling bool `json:"dangling,omitempty"`
+}
+
+func (ref Reference) String() string {
+	return fmt.Sprintf("%s.%s", ref.Address, ref.Attribute)
+}
+
+// References returns every attribute in the state which still refers to the old location of the resources to correct
+// or the unmanaged resources to move, including attributes of instances which are not selected. The `id` of the selected instances is
+// left out, that's FutureAzureID.
+func (tfstate TerraformState) References(config *Config, ris ResourcesInstanceSummary) []Reference {
+	type move struct{ from, to string }
+	var moves []move
+	selected := map[string]ResourceInstanceSummary{}
+	inSelection := map[string]bool{}
+	toCorrect := ris.ToCorrectInTFState(config)
+	for _, r := range ris {
+		if r.Unmanaged {
+			if r.Category(config) == Movable {
+				moves = append(moves, move{from: r.AzureID, to: r.FutureAzureID})
+			}
+			continue
+		}
+		inSelection[r.TerraformID] = true
+		if _, ok := toCorrect[r.TerraformID]; !ok {
+			continue
+		}
+		selected[r.TerraformID] = r
+		if !azureid.Equal(r.AzureID, r.FutureAzureID) {
+			moves = append(moves, move{from: r.AzureID, to: r.FutureAzureID})
+		}
+	}
+	// the most specific ID is rescoped first, i.e. a subnet before its virtual network
+	sort.SliceStable(moves, func(i, k int) bool { return len(moves[i].from) > len(moves[k].from) })
+
+	var references []Reference
+	for _, r := range tfstate.Resources {
+		if r.Mode != "managed" {
+			continue
+		}
+		for _, instance := range r.Instances {
+			address := instance.ID(r)
+			summary, isSelected := selected[address]
+			for attribute, value := range instance.Attributes.Strings() {
+				future := value
+				switch {
+				case isSelected && attribute == "id":
+					continue
+				case isSelected && attribute == "resource_group_name" && strings.EqualFold(value, summary.ResourceGroup):
+					if _, resourceGroup, ok := azureid.Scope(summary.FutureAzureID); ok && resourceGroup != "" {
+						future = resourceGroup
+					}
+				case isSelected && attribute == "parent_id" && azureid.Equal(value, azureid.ResourceGroupID(summary.SubscriptionID, summary.ResourceGroup)):
+					if subscriptionID, resourceGroup, ok := azureid.Scope(summary.FutureAzureID); ok && resourceGroup != "" {
+						future = azureid.ResourceGroupID(subscriptionID, resourceGroup)
+					}
+				default:
+					for _, m := range moves {
+						if rescoped, ok := azureid.Rescope(value, m.from, m.to); ok {
+							future = rescoped
+							break
+						}
+					}
+				}
+				if future != value {
+					references = append(references, Reference{Address: address, Attribute: attribute, Value: value, FutureValue: future, Dangling: !inSelection[address]})
+				}
+			}
+		}
+	}
+
+	sort.Slice(references, func(i, k int) bool {
+		if references[i].Address != references[k].Address {
+			return references[i].Address < references[k].Address
+		}
+		return references[i].Attribute < references[k].Attribute
+	})
+	return references
+}
+
+// DanglingReferences returns the references from instances which are not selected into the moved resources, like a
+// private endpoint or a role assignment in another module.
+func (tfstate TerraformState) DanglingReferences(config *Config, ris ResourcesInstanceSummary) []Reference {
+	var dangling []Reference
+	for _, ref := range tfstate.References(config, ris) {
+		if ref.Dangling {
+			dangling = append(dangling, ref)
+		}
+	}
+	return dangling
+}
+
+// IncludeReferences adds the instances with dangling references to the selection, until none are left. Only instances
+// in the source resource groups and subscriptions which can be moved are added. Instances which are in the target
+// resource group already, elsewhere, can't be moved or are not managed by the azurerm or azapi provider stay dangling.
+func (tfstate TerraformState) IncludeReferences(config *Config, ris ResourcesInstanceSummary, resourceGroupFilter, sourceSubscriptionFilter, targetResourceGroup, targetSubscriptionID string) (ResourcesInstanceSummary, error) {
+	skipped := map[string]bool{}
+	for {
+		dangling := map[string]bool{}
+		for _, ref := range tfstate.DanglingReferences(config, ris) {
+			if !skipped[ref.Address] {
+				dangling[ref.Address] = true
+			}
+		}
+		if len(dangling) == 0 {
+			return ris, nil
+		}
+
+		for _, r := range tfstate.Resources {
+			for _, instance := range r.Instances {
+				address := instance.ID(r)
+				if !dangling[address] {
+					continue
+				}
+				if summary, ok := includable(config, r, instance, resourceGroupFilter, sourceSubscriptionFilter, targetResourceGroup, targetSubscriptionID); ok {
+					ris = append(ris, summary)
+				} else {
+					skipped[address] = true
+				}
+			}
+		}
+	}
+}
+
+// includable returns the summary of an instance with dangling references when it's in the source scope and is moved
+// or corrected, see IncludeReferences.
+func includable(config *Config, r Resource, instance Instance, resourceGroupFilter, sourceSubscriptionFilter, targetResourceGroup, targetSubscriptionID string) (ResourceInstanceSummary, bool) {
+	if !r.isAccepted(config) {
+		return ResourceInstanceSummary{}, false
+	}
+	summary, err := instanceSummary(config, r, instance, "*", targetResourceGroup, targetSubscriptionID)
+	if err != nil {
+		return ResourceInstanceSummary{}, false
+	}
+	if category := summary.Category(config); category != Movable && category != OnlyMovedInTF {
+		return ResourceInstanceSummary{}, false
+	}
+	if sourceSubscriptionFilter != "*" && !strings.EqualFold(summary.SubscriptionID, sourceSubscriptionFilter) {
+		return ResourceInstanceSummary{}, false
+	}
+	if resourceGroupFilter != "*" && !strings.EqualFold(summary.ResourceGroup, resourceGroupFilter) {
+		return ResourceInstanceSummary{}, false
+	}
+	return summary, !inTarget(config, summary, targetResourceGroup, targetSubscriptionID)
+}
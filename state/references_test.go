@@ -0,0 +1,156 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	config := DefaultConfig()
+	var tfstate TerraformState
+	if err := tfstate.parseState([]byte(rewriteState)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	summary := ResourcesInstanceSummary{{
+		AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount1",
+		TerraformID:    "azurerm_storage_account.example",
+		FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/storageaccount1",
+		Type:           "azurerm_storage_account",
+		SubscriptionID: "00000000-0000-0000-0000-000000000000",
+		ResourceGroup:  "myresourcegroup",
+	}}
+
+	t.Run("Moved instance and references into it", func(t *testing.T) {
+		got := tfstate.References(config, summary)
+		wanted := []Reference{
+			{
+				Address:     "azurerm_storage_account.example",
+				Attribute:   "resource_group_name",
+				Value:       "myresourcegroup",
+				FutureValue: "myresourcegroup2",
+			},
+			{
+				Address:     "azurerm_storage_container.data",
+				Attribute:   "resource_manager_id",
+				Value:       "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount1/blobServices/default/containers/data",
+				FutureValue: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/storageaccount1/blobServices/default/containers/data",
+				Dangling:    true,
+			},
+		}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+
+	t.Run("Dangling", func(t *testing.T) {
+		var got []string
+		for _, ref := range tfstate.DanglingReferences(config, summary) {
+			got = append(got, ref.String())
+		}
+		wanted := []string{"azurerm_storage_container.data.resource_manager_id"}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+
+	t.Run("Included", func(t *testing.T) {
+		included, err := tfstate.IncludeReferences(config, summary, "myresourcegroup", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000000")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var got []string
+		for _, r := range included {
+			got = append(got, r.TerraformID)
+		}
+		wanted := []string{"azurerm_storage_account.example", "azurerm_storage_container.data"}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+		if dangling := tfstate.DanglingReferences(config, included); len(dangling) != 0 {
+			t.Errorf("got %v wanted no dangling references", dangling)
+		}
+	})
+
+	t.Run("Out of scope", func(t *testing.T) {
+		storageAccountID := summary[0].AzureID
+		referrer := func(resourceType, id string) Resource {
+			return Resource{
+				Provider:  "provider[\"registry.terraform.io/hashicorp/azurerm\"]",
+				Type:      resourceType,
+				Name:      "example",
+				Mode:      "managed",
+				Instances: []Instance{{Attributes: Attributes{ID: id, Values: map[string]interface{}{"target_resource_id": storageAccountID}}}},
+			}
+		}
+		tfstate := TerraformState{Resources: []Resource{
+			referrer("azurerm_private_endpoint", "/subscriptions/11111111-1111-1111-1111-111111111111/resourceGroups/myresourcegroup/providers/Microsoft.Network/privateEndpoints/pe"),
+			referrer("azurerm_network_interface", "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/otherresourcegroup/providers/Microsoft.Network/networkInterfaces/nic"),
+			referrer("azurerm_kubernetes_cluster", "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.ContainerService/managedClusters/aks"),
+		}}
+
+		included, err := tfstate.IncludeReferences(config, summary, "myresourcegroup", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000000")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(included, summary) {
+			t.Errorf("got %v wanted %v", included, summary)
+		}
+		if dangling := tfstate.DanglingReferences(config, included); len(dangling) != 3 {
+			t.Errorf("got %v wanted 3 dangling references", dangling)
+		}
+	})
+
+	t.Run("Role assignment", func(t *testing.T) {
+		storageAccountID := summary[0].AzureID
+		extension := func(resourceType, extensionID string) Resource {
+			return Resource{
+				Provider:  "provider[\"registry.terraform.io/hashicorp/azurerm\"]",
+				Type:      resourceType,
+				Name:      "example",
+				Mode:      "managed",
+				Instances: []Instance{{Attributes: Attributes{ID: storageAccountID + extensionID, Values: map[string]interface{}{"scope": storageAccountID}}}},
+			}
+		}
+		// extension resources can't be moved in Azure, whether their type is known or not
+		tfstate := TerraformState{Resources: []Resource{
+			extension("azurerm_role_assignment", "/providers/Microsoft.Authorization/roleAssignments/00000000-0000-0000-0000-000000000001"),
+			extension("azurerm_example_extension", "/providers/Microsoft.Example/settings/default"),
+		}}
+
+		included, err := tfstate.IncludeReferences(config, summary, "myresourcegroup", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000000")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(included, summary) {
+			t.Errorf("got %v wanted %v", included, summary)
+		}
+		for _, group := range included.MoveGroups(config) {
+			if !reflect.DeepEqual(group.AzureIDs, []string{storageAccountID}) {
+				t.Errorf("got %v wanted only %v moved", group.AzureIDs, storageAccountID)
+			}
+		}
+	})
+
+	t.Run("Nothing selected", func(t *testing.T) {
+		if got := tfstate.References(config, nil); len(got) != 0 {
+			t.Errorf("got %v wanted none", got)
+		}
+	})
+}
+
+func TestAttributesStrings(t *testing.T) {
+	var tfstate TerraformState
+	if err := tfstate.parseState([]byte(rewriteState)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := tfstate.Resources[0].Instances[0].Attributes.Strings()["network_rules[0].private_link_access[0].endpoint_resource_id"]
+	wanted := "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/MyResourceGroup/providers/Microsoft.Web/sites/app"
+	if got != wanted {
+		t.Errorf("got %v wanted %v", got, wanted)
+	}
+
+	attributes := Attributes{ID: "id", Values: map[string]interface{}{"tags": map[string]interface{}{"cost-center": "1"}}}
+	if got := attributes.Strings()[`tags["cost-center"]`]; got != "1" {
+		t.Errorf("got %v wanted %v", got, "1")
+	}
+}
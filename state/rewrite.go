@@ -0,0 +1,139 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Rewrite returns the state with the resource instances corrected in place instead of removing and importing them.
+// ids maps the address of every instance to its ID after the move and references are the other attributes to correct,
+// see References. A reference which doesn't have the value it had when planned is an error, the state has changed.
+// The rest of the document is kept as is and the serial is increased, so the result can be pushed with `terraform state push`.
+func (tfstate TerraformState) Rewrite(ids map[string]string, references []Reference) (TerraformState, error) {
+	var rewritten TerraformState
+	if len(tfstate.raw) == 0 {
+		return rewritten, fmt.Errorf("the Terraform state is empty, nothing to rewrite")
+	}
+
+	// numbers are decoded as json.Number, so these are written exactly as they were read
+	var doc map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(tfstate.raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return rewritten, fmt.Errorf("the Terraform state cannot be parsed: %v", err)
+	}
+
+	pending := map[string]Reference{}
+	for _, ref := range references {
+		pending[ref.String()] = ref
+	}
+
+	// the document is walked along the parsed resources, which are in the same order
+	resources, _ := doc["resources"].([]interface{})
+	if len(resources) != len(tfstate.Resources) {
+		return rewritten, fmt.Errorf("the Terraform state cannot be parsed: resources don't match")
+	}
+	found := map[string]bool{}
+	for i, r := range tfstate.Resources {
+		resource, _ := resources[i].(map[string]interface{})
+		instances, _ := resource["instances"].([]interface{})
+		if len(instances) != len(r.Instances) {
+			return rewritten, fmt.Errorf("the Terraform state cannot be parsed: instances of %s don't match", r.ID())
+		}
+
+		for k, instance := range r.Instances {
+			address := instance.ID(r)
+			attributes, _ := instances[k].(map[string]interface{})["attributes"].(map[string]interface{})
+			if id, ok := ids[address]; ok {
+				if attributes == nil {
+					return rewritten, fmt.Errorf("resource instance %s has no attributes to rewrite", address)
+				}
+				attributes["id"] = id
+				found[address] = true
+			}
+
+			var changed error
+			for key, value := range attributes {
+				attributes[key] = mapStrings(key, value, func(path, s string) string {
+					ref, ok := pending[address+"."+path]
+					if !ok {
+						return s
+					}
+					delete(pending, ref.String())
+					if s != ref.Value {
+						changed = fmt.Errorf("attribute %s is %q instead of %q", ref, s, ref.Value)
+						return s
+					}
+					return ref.FutureValue
+				})
+			}
+			if changed != nil {
+				return rewritten, changed
+			}
+		}
+	}
+	for address := range ids {
+		if !found[address] {
+			return rewritten, fmt.Errorf("resource instance %s is not found in the Terraform state", address)
+		}
+	}
+	for name := range pending {
+		return rewritten, fmt.Errorf("attribute %s is not found in the Terraform state", name)
+	}
+
+	serial, err := strconv.ParseInt(fmt.Sprint(doc["serial"]), 10, 64)
+	if err != nil {
+		return rewritten, fmt.Errorf("the Terraform state has no valid serial: %v", err)
+	}
+	doc["serial"] = serial + 1
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return rewritten, err
+	}
+	if err := rewritten.parseState(append(data, '\n')); err != nil {
+		return rewritten, fmt.Errorf("the rewritten Terraform state cannot be parsed: %v", err)
+	}
+	if err := tfstate.validateRewrite(rewritten, ids, references); err != nil {
+		return TerraformState{}, fmt.Errorf("the rewritten Terraform state is not valid: %v", err)
+	}
+	return rewritten, nil
+}
+
+// validateRewrite checks that only the instances to correct have changed and the result is a successor of the state.
+func (tfstate TerraformState) validateRewrite(rewritten TerraformState, ids map[string]string, references []Reference) error {
+	referring := map[string]bool{}
+	for _, ref := range references {
+		referring[ref.Address] = true
+	}
+
+	if rewritten.Lineage != tfstate.Lineage {
+		return fmt.Errorf("lineage %s differs from %s", rewritten.Lineage, tfstate.Lineage)
+	}
+	if rewritten.Serial != tfstate.Serial+1 {
+		return fmt.Errorf("serial %d doesn't follow %d", rewritten.Serial, tfstate.Serial)
+	}
+	if len(rewritten.Resources) != len(tfstate.Resources) {
+		return fmt.Errorf("%d resources instead of %d", len(rewritten.Resources), len(tfstate.Resources))
+	}
+
+	for i, r := range tfstate.Resources {
+		if rewritten.Resources[i].ID() != r.ID() || len(rewritten.Resources[i].Instances) != len(r.Instances) {
+			return fmt.Errorf("resource %s has changed", r.ID())
+		}
+		for k, instance := range r.Instances {
+			after := rewritten.Resources[i].Instances[k]
+			if id, ok := ids[instance.ID(r)]; ok {
+				if after.Attributes.ID != id {
+					return fmt.Errorf("resource instance %s has ID %s instead of %s", instance.ID(r), after.Attributes.ID, id)
+				}
+			} else if !referring[instance.ID(r)] && !reflect.DeepEqual(after.Attributes, instance.Attributes) {
+				return fmt.Errorf("resource instance %s has changed", instance.ID(r))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const rewriteState = `{
+  "version": 4,
+  "terraform_version": "1.5.7",
+  "serial": 12,
+  "lineage": "4f3a2b1c-0000-0000-0000-000000000000",
+  "outputs": {
+    "name": {"value": "storageaccount1", "type": "string"}
+  },
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "azurerm_storage_account",
+      "name": "example",
+      "provider": "provider[\"registry.terraform.io/hashicorp/azurerm\"]",
+      "instances": [
+        {
+          "schema_version": 3,
+          "attributes": {
+            "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount1",
+            "name": "storageaccount1",
+            "resource_group_name": "myresourcegroup",
+            "account_replication_type": "LRS",
+            "queue_encryption_key_type": "Service",
+            "min_tls_version": "TLS1_2",
+            "large_file_share_quota": 102400000000000001,
+            "network_rules": [
+              {
+                "private_link_access": [
+                  {"endpoint_resource_id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/MyResourceGroup/providers/Microsoft.Web/sites/app"}
+                ]
+              }
+            ]
+          },
+          "sensitive_attributes": [],
+          "private": "eyJzY2hlbWFfdmVyc2lvbiI6IjMifQ=="
+        }
+      ]
+    },
+    {
+      "mode": "managed",
+      "type": "azurerm_storage_account",
+      "name": "other",
+      "provider": "provider[\"registry.terraform.io/hashicorp/azurerm\"]",
+      "instances": [
+        {
+          "attributes": {
+            "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount2",
+            "resource_group_name": "myresourcegroup"
+          }
+        }
+      ]
+    },
+    {
+      "mode": "managed",
+      "type": "azurerm_storage_container",
+      "name": "data",
+      "provider": "provider[\"registry.terraform.io/hashicorp/azurerm\"]",
+      "instances": [
+        {
+          "attributes": {
+            "id": "https://storageaccount1.blob.core.windows.net/data",
+            "name": "data",
+            "resource_manager_id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount1/blobServices/default/containers/data",
+            "storage_account_name": "storageaccount1"
+          }
+        }
+      ]
+    }
+  ],
+  "check_results": null
+}
+`
+
+func TestRewrite(t *testing.T) {
+	config := DefaultConfig()
+	var tfstate TerraformState
+	if err := tfstate.parseState([]byte(rewriteState)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ids := map[string]string{
+		"azurerm_storage_account.example": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/storageaccount1",
+	}
+	references := tfstate.References(config, ResourcesInstanceSummary{{
+		AzureID:        "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/storageaccount1",
+		TerraformID:    "azurerm_storage_account.example",
+		FutureAzureID:  ids["azurerm_storage_account.example"],
+		Type:           "azurerm_storage_account",
+		SubscriptionID: "00000000-0000-0000-0000-000000000000",
+		ResourceGroup:  "myresourcegroup",
+	}})
+
+	t.Run("Rewritten", func(t *testing.T) {
+		rewritten, err := tfstate.Rewrite(ids, references)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rewritten.Serial != 13 || rewritten.Lineage != tfstate.Lineage {
+			t.Errorf("got serial %d and lineage %s wanted 13 and %s", rewritten.Serial, rewritten.Lineage, tfstate.Lineage)
+		}
+
+		var doc struct {
+			Outputs      map[string]interface{} `json:"outputs"`
+			CheckResults interface{}            `json:"check_results"`
+			Resources    []struct {
+				Instances []struct {
+					Private    string                 `json:"private"`
+					Attributes map[string]interface{} `json:"attributes"`
+				} `json:"instances"`
+			} `json:"resources"`
+		}
+		decoder := json.NewDecoder(bytes.NewReader(rewritten.Raw()))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		attributes := doc.Resources[0].Instances[0].Attributes
+		got := []interface{}{
+			attributes["id"],
+			attributes["resource_group_name"],
+			attributes["network_rules"].([]interface{})[0].(map[string]interface{})["private_link_access"].([]interface{})[0].(map[string]interface{})["endpoint_resource_id"],
+			attributes["large_file_share_quota"],
+			doc.Resources[0].Instances[0].Private,
+			doc.Resources[1].Instances[0].Attributes["resource_group_name"],
+			doc.Resources[2].Instances[0].Attributes["resource_manager_id"],
+			doc.Outputs["name"].(map[string]interface{})["value"],
+		}
+		wanted := []interface{}{
+			ids["azurerm_storage_account.example"],
+			"myresourcegroup2",
+			"/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/MyResourceGroup/providers/Microsoft.Web/sites/app",
+			json.Number("102400000000000001"),
+			"eyJzY2hlbWFfdmVyc2lvbiI6IjMifQ==",
+			"myresourcegroup",
+			"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Storage/storageAccounts/storageaccount1/blobServices/default/containers/data",
+			"storageaccount1",
+		}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+
+	t.Run("Unknown instance", func(t *testing.T) {
+		_, err := tfstate.Rewrite(map[string]string{"azurerm_storage_account.unknown": ids["azurerm_storage_account.example"]}, references)
+		if err == nil {
+			t.Errorf("expected an error for an instance not in the state")
+		}
+	})
+
+	t.Run("Changed reference", func(t *testing.T) {
+		changed := []Reference{{Address: "azurerm_storage_container.data", Attribute: "resource_manager_id", Value: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/other", FutureValue: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2"}}
+		_, err := tfstate.Rewrite(ids, changed)
+		if err == nil {
+			t.Errorf("expected an error for a reference which changed since it was planned")
+		}
+	})
+
+	t.Run("Pushed", func(t *testing.T) {
+		ctx := context.Background()
+		tf := NewFakeTerraform(tfstate)
+		rewritten, err := tfstate.Rewrite(ids, references)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		path := filepath.Join(t.TempDir(), "terraform.tfstate")
+		if err := os.WriteFile(path, rewritten.Raw(), 0600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := tf.Push(ctx, path, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// the same rewrite is not a successor of the pushed state anymore
+		tf.State.Resources[1].Instances[0].Attributes.ID = "changed"
+		if err := tf.Push(ctx, path, false); err == nil {
+			t.Errorf("expected an error for a push with the same serial")
+		}
+	})
+}
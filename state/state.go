@@ -1,21 +1,37 @@
 package state
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
+	"unicode"
+
+	"github.com/aristosvo/aztfmove/azureid"
 )
 
 type TerraformState struct {
-	Resources []Resource
+	Version          int        `json:"version"`
+	TerraformVersion string     `json:"terraform_version"`
+	Serial           int64      `json:"serial"`
+	Lineage          string     `json:"lineage"`
+	Resources        []Resource `json:"resources"`
+
+	// raw is the full state document, including everything not parsed above
+	raw []byte
+}
+
+// Raw returns the state document as it was pulled.
+func (s TerraformState) Raw() []byte {
+	return s.raw
 }
 
 type Resource struct {
-	Type      string
-	Name      string
-	Provider  string
-	Module    string
-	Mode      string
-	Instances []Instance
+	Type      string     `json:"type"`
+	Name      string     `json:"name"`
+	Provider  string     `json:"provider"`
+	Module    string     `json:"module,omitempty"`
+	Mode      string     `json:"mode"`
+	Instances []Instance `json:"instances"`
 }
 
 func (r Resource) ID() string {
@@ -27,8 +43,9 @@ func (r Resource) ID() string {
 }
 
 type Instance struct {
-	IndexKey   interface{} `json:"index_key,omitempty"`
-	Attributes Attributes
+	IndexKey     interface{} `json:"index_key,omitempty"`
+	Attributes   Attributes  `json:"attributes"`
+	Dependencies []string    `json:"dependencies,omitempty"`
 }
 
 func (i Instance) ID(r Resource) string {
@@ -41,50 +58,169 @@ func (i Instance) ID(r Resource) string {
 		case float64:
 			index = fmt.Sprintf("[%.0f]", v)
 		default:
-			index = fmt.Sprintf("[\"%v\"]", i.IndexKey)
+			index = fmt.Sprintf("[%s]", hclQuote(fmt.Sprint(i.IndexKey)))
 		}
 	}
 	return fmt.Sprintf("%s%s", r.ID(), index)
 }
 
-func (i Instance) SubscriptionID() string {
-	if strings.HasPrefix(i.Attributes.ID, "/subscriptions/") {
-		return strings.Split(i.Attributes.ID, "/")[2]
+// hclQuote quotes a string like Terraform does for instance keys in addresses, so it's valid in configuration as well.
+func hclQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case (r == '$' || r == '%') && strings.HasPrefix(s[i+1:], "{"):
+			// template sequences are escaped by doubling the introducer
+			b.WriteRune(r)
+			b.WriteRune(r)
+		case !unicode.IsPrint(r):
+			if r <= 0xFFFF {
+				fmt.Fprintf(&b, "\\u%04x", r)
+			} else {
+				fmt.Fprintf(&b, "\\U%08x", r)
+			}
+		default:
+			b.WriteRune(r)
+		}
 	}
+	b.WriteByte('"')
+	return b.String()
+}
 
+func (i Instance) SubscriptionID() string {
 	// attributes `key_vault_id`, `resource_manager_id` or `subscription_id` are used to find subscription if ID doesn't contain these
-	if i.Attributes.ResourceManagerID != "" && strings.HasPrefix(i.Attributes.ResourceManagerID, "/subscriptions/") {
-		return strings.Split(i.Attributes.ResourceManagerID, "/")[2]
-	}
-	if i.Attributes.KeyVaultID != "" && strings.HasPrefix(i.Attributes.KeyVaultID, "/subscriptions/") {
-		return strings.Split(i.Attributes.KeyVaultID, "/")[2]
-	}
-	if i.Attributes.SubscriptionID != "" {
-		return i.Attributes.SubscriptionID
+	for _, id := range []string{i.Attributes.ID, i.Attributes.ResourceManagerID, i.Attributes.KeyVaultID} {
+		if subscriptionID, _, ok := azureid.Scope(id); ok {
+			return subscriptionID
+		}
 	}
-
-	return ""
+	return i.Attributes.SubscriptionID
 }
 
 func (i Instance) ResourceGroup() string {
-	if strings.HasPrefix(i.Attributes.ID, fmt.Sprintf("/subscriptions/%s/resourceGroups/", i.SubscriptionID())) {
-		return strings.Split(i.Attributes.ID, "/")[4]
-	}
-
 	// attributes `key_vault_id` or `resource_manager_id` are used to find resource group if ID doesn't contain these
-	if i.Attributes.ResourceManagerID != "" && strings.HasPrefix(i.Attributes.ResourceManagerID, fmt.Sprintf("/subscriptions/%s/resourceGroups/", i.SubscriptionID())) {
-		return strings.Split(i.Attributes.ResourceManagerID, "/")[4]
-	}
-	if i.Attributes.KeyVaultID != "" && strings.HasPrefix(i.Attributes.KeyVaultID, fmt.Sprintf("/subscriptions/%s/resourceGroups/", i.SubscriptionID())) {
-		return strings.Split(i.Attributes.KeyVaultID, "/")[4]
+	for _, id := range []string{i.Attributes.ID, i.Attributes.ResourceManagerID, i.Attributes.KeyVaultID} {
+		if subscriptionID, resourceGroup, ok := azureid.Scope(id); ok && resourceGroup != "" && strings.EqualFold(subscriptionID, i.SubscriptionID()) {
+			return resourceGroup
+		}
 	}
-
 	return ""
 }
 
 type Attributes struct {
-	ID                string
+	ID                string `json:"id"`
 	KeyVaultID        string `json:"key_vault_id,omitempty"`
 	ResourceManagerID string `json:"resource_manager_id,omitempty"`
 	SubscriptionID    string `json:"subscription_id,omitempty"`
+
+	// Values contains all other attributes as they are in the state
+	Values map[string]interface{} `json:"-"`
+}
+
+func (a *Attributes) UnmarshalJSON(data []byte) error {
+	type attributes Attributes
+	var parsed attributes
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &parsed.Values); err != nil {
+		return err
+	}
+	for _, key := range []string{"id", "key_vault_id", "resource_manager_id", "subscription_id"} {
+		delete(parsed.Values, key)
+	}
+	if len(parsed.Values) == 0 {
+		parsed.Values = nil
+	}
+	*a = Attributes(parsed)
+	return nil
+}
+
+func (a Attributes) MarshalJSON() ([]byte, error) {
+	values := map[string]interface{}{"id": a.ID}
+	for key, value := range a.Values {
+		values[key] = value
+	}
+	for key, value := range map[string]string{"key_vault_id": a.KeyVaultID, "resource_manager_id": a.ResourceManagerID, "subscription_id": a.SubscriptionID} {
+		if value != "" {
+			values[key] = value
+		}
+	}
+	return json.Marshal(values)
+}
+
+// Strings returns every string attribute by its path, i.e. `network_rules[0].subnet_ids[1]` or `tags["cost-center"]`.
+func (a Attributes) Strings() map[string]string {
+	found := map[string]string{}
+	values := map[string]interface{}{"id": a.ID, "key_vault_id": a.KeyVaultID, "resource_manager_id": a.ResourceManagerID, "subscription_id": a.SubscriptionID}
+	for key, value := range a.Values {
+		values[key] = value
+	}
+	for key, value := range values {
+		mapStrings(key, value, func(path, s string) string {
+			if s != "" {
+				found[path] = s
+			}
+			return s
+		})
+	}
+	return found
+}
+
+// mapStrings calls fn for every string in a (nested) attribute value with its path and replaces the string with the
+// result.
+func mapStrings(path string, value interface{}, fn func(path, s string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return fn(path, v)
+	case []interface{}:
+		for i := range v {
+			v[i] = mapStrings(fmt.Sprintf("%s[%d]", path, i), v[i], fn)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = mapStrings(attributePath(path, k), v[k], fn)
+		}
+	}
+	return value
+}
+
+// attributePath appends a key to an attribute path, keys which aren't identifiers (like most tag names) are quoted.
+func attributePath(path, key string) string {
+	if isIdentifier(key) {
+		return path + "." + key
+	}
+	return fmt.Sprintf("%s[%s]", path, hclQuote(key))
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Instance returns the resource instance with the given address, i.e. `module.storage.azurerm_storage_account.example["a"]`.
+func (tfstate TerraformState) Instance(address string) (Instance, bool) {
+	for _, r := range tfstate.Resources {
+		for _, instance := range r.Instances {
+			if instance.ID(r) == address {
+				return instance, true
+			}
+		}
+	}
+	return Instance{}, false
 }
@@ -96,6 +96,30 @@ func TestInstanceID(t *testing.T) {
 			t.Errorf("got %s wanted %s", got, wanted)
 		}
 	})
+
+	t.Run("Index with special characters", func(t *testing.T) {
+		resource := Resource{
+			Type: "azurerm_storage_account",
+			Name: "example_storage",
+			Instances: []Instance{
+				{
+					IndexKey: "a \"b\" \\ ${c} %{d}\n",
+				},
+			},
+		}
+
+		got := resource.Instances[0].ID(resource)
+		wanted := `azurerm_storage_account.example_storage["a \"b\" \\ $${c} %%{d}\n"]`
+		if got != wanted {
+			t.Errorf("got %s wanted %s", got, wanted)
+		}
+
+		// the fake parses the address back to the same key
+		_, key, err := splitInstanceAddress(got)
+		if err != nil || key != resource.Instances[0].IndexKey {
+			t.Errorf("got %v wanted %v", key, resource.Instances[0].IndexKey)
+		}
+	})
 }
 
 func TestInstanceSubscriptionID(t *testing.T) {
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tag is a tag condition, the value can contain `*` and `?` wildcards.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+func (t Tag) String() string {
+	return t.Key + "=" + t.Value
+}
+
+// TagFilter selects resource instances by their tags, set as flag with one condition per flag. A condition is one or
+// more tags separated by `|`, like `workload=web|workload=api`, of which at least one has to match. All conditions
+// have to match. Tag names are compared case-insensitively, like Azure does.
+type TagFilter [][]Tag
+
+func (f *TagFilter) String() string {
+	var conditions []string
+	for _, condition := range *f {
+		var tags []string
+		for _, tag := range condition {
+			tags = append(tags, tag.String())
+		}
+		conditions = append(conditions, strings.Join(tags, "|"))
+	}
+	return strings.Join(conditions, ",")
+}
+
+func (f *TagFilter) Set(value string) error {
+	var condition []Tag
+	for _, tag := range strings.Split(value, "|") {
+		idx := strings.Index(tag, "=")
+		if idx < 1 {
+			return fmt.Errorf("no 'key=value' in arg: %s", tag)
+		}
+		condition = append(condition, Tag{Key: tag[:idx], Value: tag[idx+1:]})
+	}
+	*f = append(*f, condition)
+	return nil
+}
+
+// Matches reports whether the tags match all conditions of the filter.
+func (f TagFilter) Matches(tags map[string]string) bool {
+	for _, condition := range f {
+		matched := false
+		for _, tag := range condition {
+			for key, value := range tags {
+				if strings.EqualFold(key, tag.Key) && glob(tag.Value, value) {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Taggable reports whether the instance has a `tags` attribute. Instances of types without tags, like subnets, don't.
+func (a Attributes) Taggable() bool {
+	_, ok := a.Values["tags"]
+	return ok
+}
+
+// Tags returns the `tags` attribute of the instance, instances of resources without tags have none.
+func (a Attributes) Tags() map[string]string {
+	values, ok := a.Values["tags"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	tags := map[string]string{}
+	for key, value := range values {
+		if s, ok := value.(string); ok {
+			tags[key] = s
+		}
+	}
+	return tags
+}
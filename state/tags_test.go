@@ -0,0 +1,74 @@
+package state
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTagFilter(t *testing.T) {
+	config := DefaultConfig()
+	var filter TagFilter
+	for _, value := range []string{"workload=web|workload=api", "CostCenter=12*"} {
+		if err := filter.Set(value); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		tags map[string]string
+		want bool
+	}{
+		{name: "Both conditions", tags: map[string]string{"workload": "api", "costcenter": "1234"}, want: true},
+		{name: "One condition", tags: map[string]string{"workload": "api"}, want: false},
+		{name: "Other value", tags: map[string]string{"workload": "db", "costcenter": "1234"}, want: false},
+		{name: "No tags", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.Matches(tt.tags); got != tt.want {
+				t.Errorf("got %v wanted %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Selection", func(t *testing.T) {
+		state := TerraformState{
+			Resources: []Resource{
+				{
+					Provider: "provider[\"registry.terraform.io/hashicorp/azurerm\"]",
+					Type:     "azurerm_storage_account",
+					Name:     "sa",
+					Mode:     "managed",
+					Instances: []Instance{
+						{IndexKey: "web", Attributes: Attributes{ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/saweb", Values: map[string]interface{}{"tags": map[string]interface{}{"workload": "web", "costcenter": "1234"}}}},
+						{IndexKey: "db", Attributes: Attributes{ID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Storage/storageAccounts/sadb", Values: map[string]interface{}{"tags": map[string]interface{}{"workload": "db", "costcenter": "1234"}}}},
+					},
+				},
+			},
+		}
+
+		summary, err := state.Filter(config, Selection{Tags: filter}, "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000000")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(summary) != 1 || summary[0].TerraformID != "azurerm_storage_account.sa[\"web\"]" {
+			t.Errorf("got %v wanted only %v", summary, "azurerm_storage_account.sa[\"web\"]")
+		}
+
+		live := func(r Resource, instance Instance) (map[string]string, error) {
+			return map[string]string{"workload": "api", "costcenter": "1200"}, nil
+		}
+		summary, _ = state.Filter(config, Selection{Tags: filter, TagSource: live}, "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000000")
+		if len(summary) != 2 {
+			t.Errorf("got %v wanted both instances selected by the tag source", summary)
+		}
+
+		failing := func(r Resource, instance Instance) (map[string]string, error) {
+			return nil, errors.New("forbidden")
+		}
+		if _, err := state.Filter(config, Selection{Tags: filter, TagSource: failing}, "*", "00000000-0000-0000-0000-000000000000", "myresourcegroup2", "00000000-0000-0000-0000-000000000000"); err == nil {
+			t.Errorf("expected an error of the tag source")
+		}
+	})
+}
@@ -1,11 +1,14 @@
 package state
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
 	"strings"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
 )
 
 type ArrayVars []string
@@ -35,53 +38,167 @@ func (i *ArrayVarFiles) Set(list string) error {
 	return nil
 }
 
-func RemoveInstance(id string) (string, error) {
-	cmd := exec.Command("terraform", "state", "rm", id)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
+// Terraform is the set of Terraform operations aztfmove needs to correct the state after a move.
+type Terraform interface {
+	// Pull returns the current (remote) state, like `terraform state pull`.
+	Pull(ctx context.Context) (TerraformState, error)
+	// Remove removes a resource instance from the state, like `terraform state rm`.
+	Remove(ctx context.Context, address string) error
+	// Import imports an existing Azure resource into the state, like `terraform import`.
+	Import(ctx context.Context, address, id string) error
+	// Show returns the state in its JSON representation, like `terraform show -json`.
+	Show(ctx context.Context) (*tfjson.State, error)
+	// Move moves a resource (instance) to another address, like `terraform state mv`.
+	Move(ctx context.Context, source, destination string) error
+	// Push replaces the (remote) state with a state file, like `terraform state push`. Force skips the lineage and
+	// serial checks, i.e. to restore an older state.
+	Push(ctx context.Context, path string, force bool) error
+	// Apply applies the configuration of the given addresses only, like `terraform apply -target=<address> -auto-approve`.
+	Apply(ctx context.Context, targets []string) error
+	// PlanRefreshOnly reports whether the state differs from the real resources, like `terraform plan -refresh-only`.
+	PlanRefreshOnly(ctx context.Context) (bool, error)
+}
+
+// TerraformExec implements Terraform by running the `terraform` binary through terraform-exec.
+type TerraformExec struct {
+	tf       *tfexec.Terraform
+	vars     ArrayVars
+	varFiles ArrayVarFiles
+}
+
+// NewTerraformExec finds `terraform` on the PATH and prepares it for the given working directory.
+// Variables and variable files are passed on to every command that needs configuration, like `terraform import`.
+func NewTerraformExec(workingDir string, vars ArrayVars, varFiles ArrayVarFiles) (*TerraformExec, error) {
+	execPath, err := exec.LookPath("terraform")
+	if err != nil {
+		return nil, fmt.Errorf("terraform binary is not found: %v", err)
+	}
 
-	err := cmd.Run()
+	tf, err := tfexec.NewTerraform(workingDir, execPath)
 	if err != nil {
-		return out.String(), fmt.Errorf("terraform command \"terraform state rm %s\" failed : %v", id, err)
+		return nil, err
 	}
 
-	return "", nil
+	return &TerraformExec{tf: tf, vars: vars, varFiles: varFiles}, nil
 }
 
-func ImportInstance(id, newResourceID string, vars ArrayVars, varfiles ArrayVarFiles) (string, error) {
-	tfVars := append(vars, varfiles...)
-	importVars := append(tfVars, id, newResourceID)
-	cmdVars := append([]string{"import"}, importVars...)
+func (t *TerraformExec) Pull(ctx context.Context) (TerraformState, error) {
+	var tfstate TerraformState
+	out, err := t.tf.StatePull(ctx)
+	if err != nil {
+		return tfstate, fmt.Errorf("terraform command \"terraform state pull\" failed: %v", err)
+	}
+	err = tfstate.parseState([]byte(out))
 
-	cmd := exec.Command("terraform", cmdVars...)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
+	return tfstate, err
+}
 
-	err := cmd.Run()
+func (t *TerraformExec) Remove(ctx context.Context, address string) error {
+	if err := t.tf.StateRm(ctx, address); err != nil {
+		return fmt.Errorf("terraform command \"terraform state rm %s\" failed: %v", address, err)
+	}
+
+	return nil
+}
+
+func (t *TerraformExec) Import(ctx context.Context, address, id string) error {
+	var opts []tfexec.ImportOption
+	for _, v := range t.vars.values() {
+		opts = append(opts, tfexec.Var(v))
+	}
+	for _, f := range t.varFiles.paths() {
+		opts = append(opts, tfexec.VarFile(f))
+	}
+
+	if err := t.tf.Import(ctx, address, id, opts...); err != nil {
+		return fmt.Errorf("terraform command \"terraform import %s %s\" failed: %v", address, id, err)
+	}
+
+	return nil
+}
+
+func (t *TerraformExec) Show(ctx context.Context) (*tfjson.State, error) {
+	s, err := t.tf.Show(ctx)
 	if err != nil {
-		return out.String(), fmt.Errorf("terraform command \"terraform %s\" failed: %v", strings.Join(cmdVars, " "), err)
+		return nil, fmt.Errorf("terraform command \"terraform show -json\" failed: %v", err)
 	}
 
-	return "", nil
+	return s, nil
 }
 
-func (s *TerraformState) parseState(data []byte) error {
-	return json.Unmarshal(data, s)
+func (t *TerraformExec) Move(ctx context.Context, source, destination string) error {
+	if err := t.tf.StateMv(ctx, source, destination); err != nil {
+		return fmt.Errorf("terraform command \"terraform state mv %s %s\" failed: %v", source, destination, err)
+	}
+
+	return nil
 }
 
-func PullRemote() (TerraformState, error) {
-	var tfstate TerraformState
-	cmd := exec.Command("terraform", "state", "pull")
-	var out bytes.Buffer
-	cmd.Stdout = &out
+func (t *TerraformExec) Push(ctx context.Context, path string, force bool) error {
+	if err := t.tf.StatePush(ctx, path, tfexec.Force(force)); err != nil {
+		return fmt.Errorf("terraform command \"terraform state push %s\" failed: %v", path, err)
+	}
+
+	return nil
+}
+
+func (t *TerraformExec) Apply(ctx context.Context, targets []string) error {
+	var opts []tfexec.ApplyOption
+	for _, target := range targets {
+		opts = append(opts, tfexec.Target(target))
+	}
+	for _, v := range t.vars.values() {
+		opts = append(opts, tfexec.Var(v))
+	}
+	for _, f := range t.varFiles.paths() {
+		opts = append(opts, tfexec.VarFile(f))
+	}
+
+	if err := t.tf.Apply(ctx, opts...); err != nil {
+		return fmt.Errorf("terraform command \"terraform apply -target=%s\" failed: %v", strings.Join(targets, " -target="), err)
+	}
+
+	return nil
+}
 
-	err := cmd.Run()
+func (t *TerraformExec) PlanRefreshOnly(ctx context.Context) (bool, error) {
+	opts := []tfexec.PlanOption{tfexec.RefreshOnly(true)}
+	for _, v := range t.vars.values() {
+		opts = append(opts, tfexec.Var(v))
+	}
+	for _, f := range t.varFiles.paths() {
+		opts = append(opts, tfexec.VarFile(f))
+	}
+
+	changes, err := t.tf.Plan(ctx, opts...)
 	if err != nil {
-		return tfstate, err
+		return false, fmt.Errorf("terraform command \"terraform plan -refresh-only\" failed: %v", err)
 	}
-	err = tfstate.parseState(out.Bytes())
 
-	return tfstate, err
+	return changes, nil
+}
+
+// values returns the `key=value` assignments without the `-var` flags added by Set.
+func (i ArrayVars) values() []string {
+	var values []string
+	for _, v := range i {
+		if v != "-var" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// paths returns the variable file paths without the `-var-file=` prefix added by Set.
+func (i ArrayVarFiles) paths() []string {
+	var paths []string
+	for _, f := range i {
+		paths = append(paths, strings.TrimPrefix(f, "-var-file="))
+	}
+	return paths
+}
+
+func (s *TerraformState) parseState(data []byte) error {
+	s.raw = data
+	return json.Unmarshal(data, s)
 }
@@ -0,0 +1,30 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArrayVarsValues(t *testing.T) {
+	var vars ArrayVars
+	vars.Set("test1=123")
+	vars.Set("test2=312")
+
+	got := vars.values()
+	wanted := []string{"test1=123", "test2=312"}
+	if !reflect.DeepEqual(got, wanted) {
+		t.Errorf("got %v wanted %v", got, wanted)
+	}
+}
+
+func TestArrayVarFilesPaths(t *testing.T) {
+	var varFiles ArrayVarFiles
+	varFiles.Set("tst.tfvars")
+	varFiles.Set("dir/other.tfvars")
+
+	got := varFiles.paths()
+	wanted := []string{"tst.tfvars", "dir/other.tfvars"}
+	if !reflect.DeepEqual(got, wanted) {
+		t.Errorf("got %v wanted %v", got, wanted)
+	}
+}
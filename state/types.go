@@ -0,0 +1,97 @@
+package state
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TypeInfo describes how an azurerm resource type is handled in a move.
+type TypeInfo struct {
+	Category Category `json:"category"`
+	// ARMType is the Azure Resource Manager type, i.e. `Microsoft.Storage/storageAccounts`.
+	ARMType string `json:"arm_type,omitempty"`
+	// DeleteAPIVersion is the API version used to delete blocking resources of this type.
+	DeleteAPIVersion string `json:"delete_api_version,omitempty"`
+	// NoImport is set for types which can't be imported, these are only removed from the Terraform state and created
+	// again by Terraform.
+	NoImport bool   `json:"no_import,omitempty"`
+	Notes    string `json:"notes,omitempty"`
+}
+
+// TypeConfig is the format of the embedded types.json and of the files passed with `-type-config`.
+type TypeConfig struct {
+	Version int                 `json:"version"`
+	Types   map[string]TypeInfo `json:"types"`
+	// ARMTypes adds to or replaces the embedded move support matrix, see LookupMoveSupport.
+	ARMTypes map[string]MoveSupport `json:"arm_types,omitempty"`
+}
+
+const typeConfigVersion = 1
+
+//go:embed types.json
+var embeddedTypes []byte
+
+// embeddedTypeConfig contains every azurerm type which isn't simply movable, types not listed are moved in Azure and
+// corrected in Terraform. It's copied by DefaultConfig and never changed.
+var embeddedTypeConfig = mustParseTypeConfig(embeddedTypes)
+
+// LookupType returns what is known about an azurerm resource type.
+func (c *Config) LookupType(resourceType string) (TypeInfo, bool) {
+	info, ok := c.types[resourceType]
+	return info, ok
+}
+
+// LoadTypeConfig adds the types in the file to the known types, replacing the embedded ones with the same name. The same
+// goes for the move support of ARM types.
+func (c *Config) LoadTypeConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("type config %s cannot be read: %v", path, err)
+	}
+	config, err := parseTypeConfig(data)
+	if err != nil {
+		return fmt.Errorf("type config %s: %v", path, err)
+	}
+
+	for resourceType, info := range config.Types {
+		c.types[resourceType] = info
+	}
+	for armType, support := range config.ARMTypes {
+		c.moveSupport[strings.ToLower(armType)] = support
+	}
+	return nil
+}
+
+func parseTypeConfig(data []byte) (TypeConfig, error) {
+	var config TypeConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("cannot be parsed: %v", err)
+	}
+	if config.Version != typeConfigVersion {
+		return config, fmt.Errorf("version %d is not supported, only version %d is", config.Version, typeConfigVersion)
+	}
+
+	for resourceType, info := range config.Types {
+		switch info.Category {
+		case Movable, OnlyMovedInTF, NotSupported, NoMovementNeeded:
+		case Blocking:
+			if info.DeleteAPIVersion == "" {
+				return config, fmt.Errorf("type %s is blocking, but has no delete_api_version to delete it with", resourceType)
+			}
+		default:
+			return config, fmt.Errorf("type %s has unknown category %q", resourceType, info.Category)
+		}
+	}
+	return config, nil
+}
+
+func mustParseTypeConfig(data []byte) map[string]TypeInfo {
+	config, err := parseTypeConfig(data)
+	if err != nil {
+		panic(fmt.Sprintf("embedded types.json: %v", err))
+	}
+	return config.Types
+}
@@ -0,0 +1,67 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTypeConfig(t *testing.T) {
+	config := DefaultConfig()
+
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "types.json")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return path
+	}
+
+	t.Run("Override", func(t *testing.T) {
+		path := write(t, `{"version": 1, "types": {
+			"azurerm_linux_function_app": {"category": "not-supported", "arm_type": "Microsoft.Web/sites"},
+			"azurerm_subnet": {"category": "movable"}
+		}}`)
+		if err := config.LoadTypeConfig(path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got []Category
+		for _, resourceType := range []string{"azurerm_linux_function_app", "azurerm_subnet", "azurerm_storage_container", "azurerm_storage_account"} {
+			got = append(got, ResourceInstanceSummary{Type: resourceType}.Category(config))
+		}
+		wanted := []Category{NotSupported, Movable, OnlyMovedInTF, Movable}
+		for i := range wanted {
+			if got[i] != wanted[i] {
+				t.Errorf("got %v wanted %v", got, wanted)
+				break
+			}
+		}
+
+		// the type config only applies to the config it's loaded in
+		if got := (ResourceInstanceSummary{Type: "azurerm_subnet"}).Category(DefaultConfig()); got != OnlyMovedInTF {
+			t.Errorf("got %v wanted %v", got, OnlyMovedInTF)
+		}
+	})
+
+	t.Run("Unknown category", func(t *testing.T) {
+		path := write(t, `{"version": 1, "types": {"azurerm_subnet": {"category": "movable-ish"}}}`)
+		if err := config.LoadTypeConfig(path); err == nil {
+			t.Errorf("expected an error for an unknown category")
+		}
+	})
+
+	t.Run("Blocking without API version", func(t *testing.T) {
+		path := write(t, `{"version": 1, "types": {"azurerm_private_endpoint": {"category": "blocking"}}}`)
+		if err := config.LoadTypeConfig(path); err == nil {
+			t.Errorf("expected an error for a blocking type without delete API version")
+		}
+	})
+
+	t.Run("Unsupported version", func(t *testing.T) {
+		path := write(t, `{"version": 2, "types": {}}`)
+		if err := config.LoadTypeConfig(path); err == nil {
+			t.Errorf("expected an error for an unsupported version")
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package state
+
+import (
+	"github.com/aristosvo/aztfmove/azureid"
+)
+
+// Unmanaged returns the resources in Azure which are not in the Terraform state, like network interfaces, disks or
+// alert rules created by Azure itself. present are the IDs of all resources in the source resource groups of the
+// selection. The IDs are summarized as if they are moved to the target resource group, see Category.
+func (tfstate TerraformState) Unmanaged(present []string, targetResourceGroup, targetSubscriptionID string) ResourcesInstanceSummary {
+	var managed []string
+	for _, r := range tfstate.Resources {
+		if r.Mode != "managed" {
+			continue
+		}
+		for _, instance := range r.Instances {
+			managed = append(managed, instance.Attributes.ID)
+			if instance.Attributes.ResourceManagerID != "" {
+				managed = append(managed, instance.Attributes.ResourceManagerID)
+			}
+		}
+	}
+
+	var unmanaged ResourcesInstanceSummary
+	for _, id := range present {
+		if containsID(managed, id) {
+			continue
+		}
+		subscriptionID, resourceGroup, ok := azureid.Scope(id)
+		if !ok || resourceGroup == "" {
+			continue
+		}
+		futureAzureID, _ := azureid.Rescope(id, azureid.ResourceGroupID(subscriptionID, resourceGroup), azureid.ResourceGroupID(targetSubscriptionID, targetResourceGroup))
+		unmanaged = append(unmanaged, ResourceInstanceSummary{
+			AzureID:        id,
+			FutureAzureID:  futureAzureID,
+			Type:           ARMType(id),
+			SubscriptionID: subscriptionID,
+			ResourceGroup:  resourceGroup,
+			Unmanaged:      true,
+		})
+	}
+	return unmanaged
+}
+
+// Movable returns the resources which Azure can move according to the move support matrix. Resources with unknown move
+// support are left out, these are only moved when selected explicitly in Terraform.
+func (ris ResourcesInstanceSummary) Movable(config *Config) ResourcesInstanceSummary {
+	var movable ResourcesInstanceSummary
+	for _, r := range ris {
+		if category, ok := r.classify(config); ok && category == Movable {
+			movable = append(movable, r)
+		}
+	}
+	return movable
+}
+
+func containsID(ids []string, id string) bool {
+	for _, v := range ids {
+		if azureid.Equal(v, id) {
+			return true
+		}
+	}
+	return false
+}
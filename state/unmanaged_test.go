@@ -0,0 +1,55 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnmanaged(t *testing.T) {
+	config := DefaultConfig()
+	var tfstate TerraformState
+	if err := tfstate.parseState([]byte(rewriteState)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	present := []string{
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/MyResourceGroup/providers/Microsoft.Storage/storageAccounts/storageaccount1",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Compute/disks/osdisk",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Compute/galleries/gallery",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup/providers/Microsoft.Unknown/things/thing",
+	}
+	unmanaged := tfstate.Unmanaged(present, "myresourcegroup2", "00000000-0000-0000-0000-000000000000")
+
+	t.Run("Not in the state", func(t *testing.T) {
+		var got []string
+		for _, r := range unmanaged {
+			got = append(got, r.AzureID)
+		}
+		wanted := present[1:]
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+
+	t.Run("Movable", func(t *testing.T) {
+		got := unmanaged.Movable(config)
+		wanted := ResourcesInstanceSummary{{
+			AzureID:        present[1],
+			FutureAzureID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myresourcegroup2/providers/Microsoft.Compute/disks/osdisk",
+			Type:           "Microsoft.Compute/disks",
+			SubscriptionID: "00000000-0000-0000-0000-000000000000",
+			ResourceGroup:  "myresourcegroup",
+			Unmanaged:      true,
+		}}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+
+	t.Run("Only moved in Azure", func(t *testing.T) {
+		got := []interface{}{len(unmanaged.Movable(config).ToCorrectInTFState(config)), len(unmanaged.Movable(config).MoveGroups(config))}
+		wanted := []interface{}{0, 1}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("got %v wanted %v", got, wanted)
+		}
+	})
+}